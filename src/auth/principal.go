@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"context"
+	"time"
+)
+
+// The authenticated identity behind a request, as read from its token.
+type Principal struct {
+	UserID    string
+	Username  string
+	RoleSlug  string
+	RoleLabel string
+	TokenID   string
+	// Last moment the token is accepted, clock skew leeway included.
+	// Zero when it never expires.
+	ExpiresAt time.Time
+}
+
+type contextKey int
+
+const (
+	principalKey contextKey = iota
+	accessTokenKey
+	tokenSourceKey
+)
+
+// Returns a copy of ctx carrying the principal.
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey, principal)
+}
+
+// Returns the principal of an authenticated request, if there is one.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey).(Principal)
+	return principal, ok
+}
+
+// Returns a copy of ctx carrying the raw access token the principal was
+// read from, so it can be forwarded to the upstream services.
+func WithAccessToken(ctx context.Context, accessToken string) context.Context {
+	return context.WithValue(ctx, accessTokenKey, accessToken)
+}
+
+// Returns the raw access token of an authenticated request.
+func AccessTokenFromContext(ctx context.Context) string {
+	accessToken, _ := ctx.Value(accessTokenKey).(string)
+	return accessToken
+}
@@ -0,0 +1,23 @@
+package auth
+
+import "context"
+
+// Where the access token of a request was read from.
+type TokenSource string
+
+const (
+	TokenSourceBearer TokenSource = "bearer"
+	TokenSourceCookie TokenSource = "cookie"
+	TokenSourceQuery  TokenSource = "query"
+)
+
+// Returns a copy of ctx carrying the source of the access token.
+func WithTokenSource(ctx context.Context, source TokenSource) context.Context {
+	return context.WithValue(ctx, tokenSourceKey, source)
+}
+
+// Returns the source of the access token of an authenticated request.
+func TokenSourceFromContext(ctx context.Context) (TokenSource, bool) {
+	source, ok := ctx.Value(tokenSourceKey).(TokenSource)
+	return source, ok
+}
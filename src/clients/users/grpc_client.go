@@ -2,22 +2,27 @@ package users
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"time"
 
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/plagioriginal/api-gateway/domain"
 	users "github.com/plagioriginal/users-service-grpc/users"
+	"google.golang.org/grpc/metadata"
 )
 
+// Metadata key of the ID of the request a call is made for.
+const requestIDMetadataKey = "x-request-id"
+
 type GrpcUsersClient struct {
 	UsersClient    users.UsersClient
-	Logger         *log.Logger
+	Logger         *slog.Logger
 	contextTimeout time.Duration
 }
 
 func New(
 	uc users.UsersClient,
-	l *log.Logger,
+	l *slog.Logger,
 	contextTimeout time.Duration,
 ) domain.UsersClient {
 	return GrpcUsersClient{
@@ -27,9 +32,18 @@ func New(
 	}
 }
 
+// The context of a call: bounded by the client timeout, and carrying the
+// request ID so the users service can log it too.
+func (as GrpcUsersClient) callContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if requestID := middleware.GetReqID(ctx); requestID != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, requestIDMetadataKey, requestID)
+	}
+	return context.WithTimeout(ctx, as.contextTimeout)
+}
+
 // Login route handler
 func (as GrpcUsersClient) Login(ctx context.Context, loginRequest domain.LoginRequest) (*domain.TokenResponse, error) {
-	ctx, cancel := context.WithTimeout(ctx, as.contextTimeout)
+	ctx, cancel := as.callContext(ctx)
 	defer cancel()
 
 	res, err := as.UsersClient.Login(ctx, &users.LoginRequest{
@@ -45,7 +59,7 @@ func (as GrpcUsersClient) Login(ctx context.Context, loginRequest domain.LoginRe
 
 // Refresh JWT token handler.
 func (as GrpcUsersClient) RefreshJWT(ctx context.Context, refreshToken string) (*domain.TokenResponse, error) {
-	ctx, cancel := context.WithTimeout(ctx, as.contextTimeout)
+	ctx, cancel := as.callContext(ctx)
 	defer cancel()
 
 	res, err := as.UsersClient.Refresh(ctx, &users.RefreshRequest{
@@ -60,7 +74,7 @@ func (as GrpcUsersClient) RefreshJWT(ctx context.Context, refreshToken string) (
 
 // Handles the user logout.
 func (as GrpcUsersClient) Logout(ctx context.Context, refreshToken string) (*domain.TokenResponse, error) {
-	ctx, cancel := context.WithTimeout(ctx, as.contextTimeout)
+	ctx, cancel := as.callContext(ctx)
 	defer cancel()
 
 	res, err := as.UsersClient.Logout(ctx, &users.RefreshRequest{
@@ -72,6 +86,19 @@ func (as GrpcUsersClient) Logout(ctx context.Context, refreshToken string) (*dom
 	return mapGrpcTokenResponseToDomain(res), nil
 }
 
+// Creates a new user.
 func (as GrpcUsersClient) AddUser(ctx context.Context, userRequest domain.AddUserRequest) (*domain.User, error) {
-	return nil, nil
+	ctx, cancel := as.callContext(ctx)
+	defer cancel()
+
+	res, err := as.UsersClient.AddUser(ctx, &users.NewUserRequest{
+		Username:    userRequest.Username,
+		Password:    userRequest.Password,
+		Role:        userRequest.Role,
+		AccessToken: userRequest.JwtToken,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return mapGrpcUserResponseToDomain(res), nil
 }
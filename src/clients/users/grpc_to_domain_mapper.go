@@ -22,6 +22,10 @@ func mapGrpcTokenResponseToDomain(in *usersGrpc.TokenResponse) *domain.TokenResp
 	}
 }
 
+// Maps the users of the AddUser, Login and Refresh responses.
+// The list, get, update and delete operations will map their responses
+// here too, once users-service-grpc has their RPCs. v1.1.0 only has
+// AddUser, Login, Logout and Refresh.
 func mapGrpcUserResponseToDomain(in *usersGrpc.UserResponse) *domain.User {
 	if in == nil {
 		return nil
@@ -0,0 +1,289 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/plagioriginal/api-gateway/helpers"
+	"gopkg.in/yaml.v3"
+)
+
+// Settings of the gateway.
+// Loaded from the YAML file of CONFIG_FILE when set, then from the
+// environment variables, which take precedence.
+type Config struct {
+	// Picks the CORS profile, e.g. "development" or "production".
+	Environment string    `yaml:"environment"`
+	Server      Server    `yaml:"server"`
+	Upstreams   Upstreams `yaml:"upstreams"`
+	JWT         JWT       `yaml:"jwt"`
+	Auth        Auth      `yaml:"auth"`
+	Cookies     Cookies   `yaml:"cookies"`
+	CORS        CORS      `yaml:"cors"`
+	Proxy       Proxy     `yaml:"proxy"`
+	Metrics     Metrics   `yaml:"metrics"`
+	Tracing     Tracing   `yaml:"tracing"`
+	Logging     Logging   `yaml:"logging"`
+}
+
+type Server struct {
+	Port string `yaml:"port"`
+	// Time a request may take before it is canceled.
+	RequestTimeout time.Duration `yaml:"requestTimeout"`
+	// Time the running requests are given to finish on shutdown.
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout"`
+	// Time the gateway keeps serving while reported as not ready,
+	// before shutting down, so load balancers stop sending traffic.
+	DrainDelay time.Duration `yaml:"drainDelay"`
+	// Limits of the http server. Zero means no limit.
+	ReadTimeout  time.Duration `yaml:"readTimeout"`
+	WriteTimeout time.Duration `yaml:"writeTimeout"`
+	IdleTimeout  time.Duration `yaml:"idleTimeout"`
+	// Addresses or CIDR ranges of the proxies whose X-Request-ID is kept.
+	TrustedProxies []string `yaml:"trustedProxies"`
+}
+
+// The gRPC services behind the gateway.
+type Upstreams struct {
+	UsersHost string `yaml:"usersHost"`
+	// Time each call may take.
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+type JWT struct {
+	// Key of the HMAC algorithms.
+	Secret            string   `yaml:"secret"`
+	AllowedAlgorithms []string `yaml:"allowedAlgorithms"`
+	// Keys of the asymmetric algorithms. At most one of both.
+	JWKSFile            string        `yaml:"jwksFile"`
+	JWKSURL             string        `yaml:"jwksUrl"`
+	JWKSRefreshInterval time.Duration `yaml:"jwksRefreshInterval"`
+	Issuers             []string      `yaml:"issuers"`
+	Audience            string        `yaml:"audience"`
+	RequiredClaims      []string      `yaml:"requiredClaims"`
+	MaxAge              time.Duration `yaml:"maxAge"`
+	Leeway              time.Duration `yaml:"leeway"`
+	// Reads the user ID from "iss" on tokens without "sub". Only works
+	// while Issuers is empty, since "iss" can't hold both.
+	LegacyIssuerAsSubject bool `yaml:"legacyIssuerAsSubject"`
+}
+
+type Auth struct {
+	// Query parameter holding the access token on WebSocket upgrades.
+	QueryTokenParam string `yaml:"queryTokenParam"`
+	// Time the refresh of an expired token may take.
+	RefreshTimeout time.Duration `yaml:"refreshTimeout"`
+	// Time a refreshed token pair is shared with the concurrent requests
+	// of the same session.
+	RefreshGracePeriod time.Duration `yaml:"refreshGracePeriod"`
+}
+
+type Cookies struct {
+	SameSite   string        `yaml:"sameSite"`
+	Domain     string        `yaml:"domain"`
+	Path       string        `yaml:"path"`
+	Secure     bool          `yaml:"secure"`
+	RefreshTTL time.Duration `yaml:"refreshTtl"`
+	// Base64 "hashKey:blockKey" pairs, the newest first.
+	Keys []string `yaml:"keys"`
+	// File with one key pair per line. Takes precedence over Keys.
+	KeysFile string `yaml:"keysFile"`
+}
+
+type CORS struct {
+	// JSON file of the profiles per environment.
+	ProfilesFile string `yaml:"profilesFile"`
+	// Replaces the origins of the profile.
+	AllowedOrigins []string `yaml:"allowedOrigins"`
+}
+
+type Proxy struct {
+	// JSON route table of the proxied HTTP services.
+	RoutesFile string `yaml:"routesFile"`
+}
+
+type Metrics struct {
+	// Address of the metrics listener, kept apart from the API so it
+	// isn't exposed with it. Empty disables the listener.
+	Address string `yaml:"address"`
+}
+
+type Tracing struct {
+	ServiceName string `yaml:"serviceName"`
+	// Where the spans go: "otlp", "stdout", "file" or "none".
+	Exporter string `yaml:"exporter"`
+	// OTLP/HTTP collector, e.g. "http://otel-collector:4318".
+	OTLPEndpoint string `yaml:"otlpEndpoint"`
+	// File the "file" exporter appends the spans to.
+	File string `yaml:"file"`
+	// "always_on", "always_off" or "traceidratio", following the
+	// sampling decision of the caller when it sent a traceparent.
+	Sampler string `yaml:"sampler"`
+	// Share of the traces kept by the "traceidratio" sampler.
+	SampleRatio float64 `yaml:"sampleRatio"`
+}
+
+type Logging struct {
+	// Initial level: "debug", "info", "warn" or "error". Can be changed
+	// while running, on the internal listener.
+	Level string `yaml:"level"`
+	// "json" or "text".
+	Format string `yaml:"format"`
+}
+
+// The settings used when nothing is configured.
+func Default() Config {
+	return Config{
+		Environment: "development",
+		Server: Server{
+			Port:            "8081",
+			RequestTimeout:  60 * time.Second,
+			ShutdownTimeout: 3 * time.Second,
+			DrainDelay:      5 * time.Second,
+			IdleTimeout:     120 * time.Second,
+		},
+		Upstreams: Upstreams{
+			Timeout: 3 * time.Second,
+		},
+		JWT: JWT{
+			AllowedAlgorithms:     []string{"HS256"},
+			JWKSRefreshInterval:   5 * time.Minute,
+			RequiredClaims:        []string{"exp"},
+			LegacyIssuerAsSubject: true,
+		},
+		Auth: Auth{
+			RefreshTimeout:     2 * time.Second,
+			RefreshGracePeriod: 10 * time.Second,
+		},
+		Cookies: Cookies{
+			SameSite:   "lax",
+			Path:       "/",
+			Secure:     true,
+			RefreshTTL: 7 * 24 * time.Hour,
+		},
+		Metrics: Metrics{
+			// Only reachable from the host, set ":9090" to let a scraper
+			// on the private network in.
+			Address: "127.0.0.1:9090",
+		},
+		Tracing: Tracing{
+			ServiceName: "api-gateway",
+			Exporter:    "none",
+			Sampler:     "always_on",
+			SampleRatio: 1,
+		},
+		Logging: Logging{
+			Level:  "info",
+			Format: "json",
+		},
+	}
+}
+
+// Loads and validates the settings.
+func Load() (Config, error) {
+	cfg := Default()
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := cfg.readFile(path); err != nil {
+			return cfg, err
+		}
+	}
+
+	if err := cfg.readEnv(); err != nil {
+		return cfg, err
+	}
+
+	return cfg, cfg.Validate()
+}
+
+func (cfg *Config) readFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config: %w", err)
+	}
+
+	if err := yaml.Unmarshal(content, cfg); err != nil {
+		return fmt.Errorf("decoding config %s: %w", path, err)
+	}
+	return nil
+}
+
+// Checks the settings the gateway can't start without, and reports
+// every problem at once.
+func (cfg Config) Validate() error {
+	problems := []string{}
+
+	if cfg.Server.Port == "" {
+		problems = append(problems, "the server port is required (API_PORT)")
+	}
+	if cfg.Server.RequestTimeout <= 0 {
+		problems = append(problems, "the request timeout must be positive (SERVER_REQUEST_TIMEOUT)")
+	}
+	if cfg.Server.WriteTimeout > 0 && cfg.Server.WriteTimeout <= cfg.Server.RequestTimeout {
+		problems = append(problems, "the write timeout must be longer than the request timeout (SERVER_WRITE_TIMEOUT)")
+	}
+	for _, proxy := range cfg.Server.TrustedProxies {
+		if _, err := helpers.ParsePrefix(proxy); err != nil {
+			problems = append(problems, fmt.Sprintf("the trusted proxy %q is not an address or CIDR range (TRUSTED_PROXIES)", proxy))
+		}
+	}
+	if cfg.Upstreams.UsersHost == "" {
+		problems = append(problems, "the users service host is required (USERS_SERVICE_HOST)")
+	}
+	if cfg.Upstreams.Timeout <= 0 {
+		problems = append(problems, "the upstream timeout must be positive (UPSTREAM_TIMEOUT)")
+	}
+	if cfg.JWT.Secret == "" && usesHMAC(cfg.JWT.AllowedAlgorithms) {
+		problems = append(problems, "the jwt secret is required by the HMAC algorithms (JWT_GENERATOR_SECRET)")
+	}
+	if cfg.JWT.JWKSFile != "" && cfg.JWT.JWKSURL != "" {
+		problems = append(problems, "only one of the jwks file and url can be set (JWT_JWKS_FILE, JWT_JWKS_URL)")
+	}
+	if cfg.JWT.JWKSURL != "" && cfg.JWT.JWKSRefreshInterval <= 0 {
+		problems = append(problems, "the jwks refresh interval must be positive (JWT_JWKS_REFRESH_INTERVAL)")
+	}
+	if cfg.Auth.RefreshTimeout <= 0 {
+		problems = append(problems, "the refresh timeout must be positive (AUTH_REFRESH_TIMEOUT)")
+	}
+	if cfg.Auth.RefreshGracePeriod <= 0 {
+		problems = append(problems, "the refresh grace period must be positive (AUTH_REFRESH_GRACE_PERIOD)")
+	}
+	if cfg.Metrics.Address != "" && strings.HasSuffix(cfg.Metrics.Address, ":"+cfg.Server.Port) {
+		problems = append(problems, "the metrics address must not use the server port (METRICS_ADDRESS)")
+	}
+	if !helpers.InArray(cfg.Tracing.Exporter, []string{"otlp", "stdout", "file", "none"}) {
+		problems = append(problems, "the tracing exporter must be otlp, stdout, file or none (OTEL_TRACES_EXPORTER)")
+	}
+	if cfg.Tracing.Exporter == "file" && cfg.Tracing.File == "" {
+		problems = append(problems, "the file exporter needs a file (TRACING_FILE)")
+	}
+	if !helpers.InArray(cfg.Tracing.Sampler, []string{"always_on", "always_off", "traceidratio"}) {
+		problems = append(problems, "the tracing sampler must be always_on, always_off or traceidratio (OTEL_TRACES_SAMPLER)")
+	}
+	if cfg.Tracing.SampleRatio < 0 || cfg.Tracing.SampleRatio > 1 {
+		problems = append(problems, "the tracing sample ratio must be between 0 and 1 (OTEL_TRACES_SAMPLER_ARG)")
+	}
+	if !helpers.InArray(strings.ToLower(cfg.Logging.Level), []string{"debug", "info", "warn", "error"}) {
+		problems = append(problems, "the log level must be debug, info, warn or error (LOG_LEVEL)")
+	}
+	if !helpers.InArray(cfg.Logging.Format, []string{"json", "text"}) {
+		problems = append(problems, "the log format must be json or text (LOG_FORMAT)")
+	}
+	if len(cfg.Cookies.Keys) == 0 && cfg.Cookies.KeysFile == "" {
+		problems = append(problems, "the cookie keys are required (COOKIE_KEYS or COOKIE_KEYS_FILE)")
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func usesHMAC(algorithms []string) bool {
+	return helpers.InArray("HS256", algorithms) ||
+		helpers.InArray("HS384", algorithms) ||
+		helpers.InArray("HS512", algorithms)
+}
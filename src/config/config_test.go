@@ -0,0 +1,82 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		change      func(cfg *Config)
+		wantProblem string
+	}{
+		{name: "defaults", change: func(cfg *Config) {}},
+		{
+			name: "jwks url with refresh interval",
+			change: func(cfg *Config) {
+				cfg.JWT.JWKSURL = "https://users-service/.well-known/jwks.json"
+			},
+		},
+		{
+			name: "jwks url without refresh interval",
+			change: func(cfg *Config) {
+				cfg.JWT.JWKSURL = "https://users-service/.well-known/jwks.json"
+				cfg.JWT.JWKSRefreshInterval = 0
+			},
+			wantProblem: "JWT_JWKS_REFRESH_INTERVAL",
+		},
+		{
+			name: "jwks url with negative refresh interval",
+			change: func(cfg *Config) {
+				cfg.JWT.JWKSURL = "https://users-service/.well-known/jwks.json"
+				cfg.JWT.JWKSRefreshInterval = -time.Minute
+			},
+			wantProblem: "JWT_JWKS_REFRESH_INTERVAL",
+		},
+		{
+			name: "jwks file without refresh interval",
+			change: func(cfg *Config) {
+				cfg.JWT.JWKSFile = "jwks.json"
+				cfg.JWT.JWKSRefreshInterval = 0
+			},
+		},
+		{
+			name:        "no refresh grace period",
+			change:      func(cfg *Config) { cfg.Auth.RefreshGracePeriod = 0 },
+			wantProblem: "AUTH_REFRESH_GRACE_PERIOD",
+		},
+		{
+			name:        "no refresh timeout",
+			change:      func(cfg *Config) { cfg.Auth.RefreshTimeout = 0 },
+			wantProblem: "AUTH_REFRESH_TIMEOUT",
+		},
+		{
+			name:        "no users service host",
+			change:      func(cfg *Config) { cfg.Upstreams.UsersHost = "" },
+			wantProblem: "USERS_SERVICE_HOST",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			cfg.Upstreams.UsersHost = "users-service:8080"
+			cfg.JWT.Secret = "secret"
+			cfg.Cookies.Keys = []string{"hash:block"}
+			tt.change(&cfg)
+
+			err := cfg.Validate()
+			if tt.wantProblem == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want no error", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantProblem) {
+				t.Errorf("Validate() = %v, want a problem with %s", err, tt.wantProblem)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Reads the environment variables over the settings, ignoring the
+// empty ones.
+func (cfg *Config) readEnv() error {
+	env := envReader{}
+
+	env.string("APP_ENV", &cfg.Environment)
+
+	env.string("API_PORT", &cfg.Server.Port)
+	env.duration("SERVER_REQUEST_TIMEOUT", &cfg.Server.RequestTimeout)
+	env.duration("SERVER_SHUTDOWN_TIMEOUT", &cfg.Server.ShutdownTimeout)
+	env.duration("SERVER_DRAIN_DELAY", &cfg.Server.DrainDelay)
+	env.duration("SERVER_READ_TIMEOUT", &cfg.Server.ReadTimeout)
+	env.duration("SERVER_WRITE_TIMEOUT", &cfg.Server.WriteTimeout)
+	env.duration("SERVER_IDLE_TIMEOUT", &cfg.Server.IdleTimeout)
+	env.list("TRUSTED_PROXIES", &cfg.Server.TrustedProxies)
+
+	env.string("USERS_SERVICE_HOST", &cfg.Upstreams.UsersHost)
+	env.duration("UPSTREAM_TIMEOUT", &cfg.Upstreams.Timeout)
+
+	env.string("JWT_GENERATOR_SECRET", &cfg.JWT.Secret)
+	env.list("JWT_ALLOWED_ALGORITHMS", &cfg.JWT.AllowedAlgorithms)
+	env.string("JWT_JWKS_FILE", &cfg.JWT.JWKSFile)
+	env.string("JWT_JWKS_URL", &cfg.JWT.JWKSURL)
+	env.duration("JWT_JWKS_REFRESH_INTERVAL", &cfg.JWT.JWKSRefreshInterval)
+	env.list("JWT_ISSUERS", &cfg.JWT.Issuers)
+	env.string("JWT_AUDIENCE", &cfg.JWT.Audience)
+	env.list("JWT_REQUIRED_CLAIMS", &cfg.JWT.RequiredClaims)
+	env.duration("JWT_MAX_AGE", &cfg.JWT.MaxAge)
+	env.duration("JWT_LEEWAY", &cfg.JWT.Leeway)
+	env.bool("JWT_LEGACY_ISSUER_SUBJECT", &cfg.JWT.LegacyIssuerAsSubject)
+
+	env.string("AUTH_QUERY_TOKEN_PARAM", &cfg.Auth.QueryTokenParam)
+	env.duration("AUTH_REFRESH_TIMEOUT", &cfg.Auth.RefreshTimeout)
+	env.duration("AUTH_REFRESH_GRACE_PERIOD", &cfg.Auth.RefreshGracePeriod)
+
+	env.string("COOKIE_SAMESITE", &cfg.Cookies.SameSite)
+	env.string("COOKIE_DOMAIN", &cfg.Cookies.Domain)
+	env.string("COOKIE_PATH", &cfg.Cookies.Path)
+	env.bool("COOKIE_SECURE", &cfg.Cookies.Secure)
+	env.duration("COOKIE_REFRESH_TTL", &cfg.Cookies.RefreshTTL)
+	env.list("COOKIE_KEYS", &cfg.Cookies.Keys)
+	env.string("COOKIE_KEYS_FILE", &cfg.Cookies.KeysFile)
+
+	env.string("CORS_PROFILES_FILE", &cfg.CORS.ProfilesFile)
+	env.list("CORS_ALLOWED_ORIGINS", &cfg.CORS.AllowedOrigins)
+
+	env.string("PROXY_ROUTES_FILE", &cfg.Proxy.RoutesFile)
+
+	if raw, found := os.LookupEnv("METRICS_ADDRESS"); found {
+		// Set empty to disable the listener.
+		cfg.Metrics.Address = raw
+	}
+
+	env.string("OTEL_SERVICE_NAME", &cfg.Tracing.ServiceName)
+	env.string("OTEL_TRACES_EXPORTER", &cfg.Tracing.Exporter)
+	env.string("OTEL_EXPORTER_OTLP_ENDPOINT", &cfg.Tracing.OTLPEndpoint)
+	env.string("TRACING_FILE", &cfg.Tracing.File)
+	env.string("OTEL_TRACES_SAMPLER", &cfg.Tracing.Sampler)
+	env.float("OTEL_TRACES_SAMPLER_ARG", &cfg.Tracing.SampleRatio)
+
+	env.string("LOG_LEVEL", &cfg.Logging.Level)
+	env.string("LOG_FORMAT", &cfg.Logging.Format)
+
+	if len(env.problems) > 0 {
+		return errors.New("invalid environment: " + strings.Join(env.problems, "; "))
+	}
+	return nil
+}
+
+// Reads typed environment variables, collecting the malformed ones.
+type envReader struct {
+	problems []string
+}
+
+func (e *envReader) string(name string, target *string) {
+	if raw := os.Getenv(name); raw != "" {
+		*target = raw
+	}
+}
+
+// Comma separated, empty items are ignored.
+func (e *envReader) list(name string, target *[]string) {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return
+	}
+
+	items := []string{}
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	*target = items
+}
+
+func (e *envReader) bool(name string, target *bool) {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return
+	}
+
+	parsed, err := strconv.ParseBool(raw)
+	if err != nil {
+		e.problems = append(e.problems, fmt.Sprintf("%s must be true or false", name))
+		return
+	}
+	*target = parsed
+}
+
+func (e *envReader) float(name string, target *float64) {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return
+	}
+
+	parsed, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		e.problems = append(e.problems, fmt.Sprintf("%s must be a number", name))
+		return
+	}
+	*target = parsed
+}
+
+// A Go duration like "1m30s". Plain numbers are read as seconds.
+func (e *envReader) duration(name string, target *time.Duration) {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return
+	}
+
+	if seconds, err := strconv.Atoi(raw); err == nil {
+		*target = time.Duration(seconds) * time.Second
+		return
+	}
+
+	parsed, err := time.ParseDuration(raw)
+	if err != nil {
+		e.problems = append(e.problems, fmt.Sprintf("%s must be a duration like 30s", name))
+		return
+	}
+	*target = parsed
+}
@@ -1,30 +1,52 @@
 package cookies
 
 import (
+	"encoding/base64"
+	"errors"
 	"net/http"
 	"time"
 
+	"github.com/golang-jwt/jwt"
 	"github.com/gorilla/securecookie"
 	"github.com/plagioriginal/api-gateway/domain"
+	"github.com/plagioriginal/api-gateway/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
 	accessTokenKey  string = "access-token"
 	refreshTokenKey string = "refresh-token"
+	csrfTokenKey    string = "csrf-token"
 )
 
+// Names of the cookies of the gateway session, which only the gateway
+// reads.
+func SessionCookieNames() []string {
+	return []string{accessTokenKey, refreshTokenKey, csrfTokenKey}
+}
+
 // Settings for the cookies.
 type CookieSettings struct {
-	Name  string
-	Value string
+	Name      string
+	Value     string
+	ExpiresAt time.Time
 }
 
 type CookieHandler struct {
-	cookieEncoder *securecookie.SecureCookie
+	codecs []securecookie.Codec
+	policy Policy
 }
 
-func New(cookieEncoder *securecookie.SecureCookie) domain.CookieHandler {
-	return CookieHandler{cookieEncoder: cookieEncoder}
+// Returns a new cookie handler. New cookies are encoded with the first
+// codec, and every codec is tried when decoding, so cookies set before
+// a key rotation are still read.
+func New(codecs []securecookie.Codec, policy Policy) domain.CookieHandler {
+	return CookieHandler{
+		codecs: codecs,
+		policy: policy,
+	}
 }
 
 func (c CookieHandler) GetAccessToken(r *http.Request) string {
@@ -38,11 +60,16 @@ func (c CookieHandler) GetRefreshToken(r *http.Request) string {
 // Gets a cookie value
 func (c CookieHandler) getCookieValue(r *http.Request, cookieName string) string {
 	if cookie, err := r.Cookie(cookieName); err == nil {
-		var value string
+		_, span := tracing.Tracer().Start(r.Context(), "cookie.decode", trace.WithAttributes(
+			attribute.String("cookie.name", cookieName),
+		))
+		defer span.End()
 
-		if err = c.cookieEncoder.Decode(cookieName, cookie.Value, &value); err == nil {
+		var value string
+		if err = securecookie.DecodeMulti(cookieName, cookie.Value, &value, c.codecs...); err == nil {
 			return value
 		}
+		span.SetStatus(codes.Error, "cookie could not be decoded")
 	}
 
 	return ""
@@ -52,12 +79,14 @@ func (c CookieHandler) getCookieValue(r *http.Request, cookieName string) string
 func (c CookieHandler) GenerateCookiesFromTokens(w http.ResponseWriter, accessToken string, refreshToken string) {
 	cookies := []CookieSettings{
 		{
-			Name:  accessTokenKey,
-			Value: accessToken,
+			Name:      accessTokenKey,
+			Value:     accessToken,
+			ExpiresAt: tokenExpiry(accessToken),
 		},
 		{
-			Name:  refreshTokenKey,
-			Value: refreshToken,
+			Name:      refreshTokenKey,
+			Value:     refreshToken,
+			ExpiresAt: time.Now().Add(c.policy.RefreshTTL),
 		},
 	}
 
@@ -66,19 +95,87 @@ func (c CookieHandler) GenerateCookiesFromTokens(w http.ResponseWriter, accessTo
 	}
 }
 
+// Gets the CSRF token the browser was issued.
+func (c CookieHandler) GetCSRFToken(r *http.Request) string {
+	return c.getCookieValue(r, csrfTokenKey)
+}
+
+// Stores the CSRF token on a signed cookie, living as long as the session.
+func (c CookieHandler) SetCSRFCookie(w http.ResponseWriter, token string) {
+	c.setUpHttpOnlyCookie(w, CookieSettings{
+		Name:      csrfTokenKey,
+		Value:     token,
+		ExpiresAt: time.Now().Add(c.policy.RefreshTTL),
+	})
+}
+
+// Stores a new random CSRF token on the cookie, replacing the one the
+// browser held, and returns it.
+func (c CookieHandler) IssueCSRFToken(w http.ResponseWriter) (string, error) {
+	raw := securecookie.GenerateRandomKey(32)
+	if raw == nil {
+		return "", errors.New("couldn't generate a csrf token")
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(raw)
+	c.SetCSRFCookie(w, token)
+	return token, nil
+}
+
+// Deletes the session cookies from the browser, CSRF token included.
+// Browsers only delete a cookie matching the one they hold, so the
+// same attributes it was set with are sent.
+func (c CookieHandler) ClearTokenCookies(w http.ResponseWriter) {
+	for _, name := range SessionCookieNames() {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Value:    "",
+			HttpOnly: true,
+			Path:     c.policy.Path,
+			Domain:   c.policy.Domain,
+			Secure:   c.policy.Secure,
+			SameSite: c.policy.SameSite,
+			Expires:  time.Unix(0, 0),
+			MaxAge:   -1,
+		})
+	}
+}
+
 // Sets up the http only cookies.
 func (c CookieHandler) setUpHttpOnlyCookie(w http.ResponseWriter, cookie CookieSettings) {
-	encodedValue, err := c.cookieEncoder.Encode(cookie.Name, cookie.Value)
+	encodedValue, err := securecookie.EncodeMulti(cookie.Name, cookie.Value, c.codecs...)
 	if err != nil {
 		return
 	}
 
-	http.SetCookie(w, &http.Cookie{
+	httpCookie := &http.Cookie{
 		Name:     cookie.Name,
 		Value:    encodedValue,
 		HttpOnly: true,
-		Path:     "/",
-		Expires:  time.Now().Add(time.Hour * 24 * 7),
-		Secure:   true,
-	})
+		Path:     c.policy.Path,
+		Domain:   c.policy.Domain,
+		Secure:   c.policy.Secure,
+		SameSite: c.policy.SameSite,
+	}
+
+	// Without an expiry, it is kept for the browser session.
+	if !cookie.ExpiresAt.IsZero() {
+		httpCookie.Expires = cookie.ExpiresAt
+		httpCookie.MaxAge = int(time.Until(cookie.ExpiresAt).Seconds())
+		if httpCookie.MaxAge <= 0 {
+			httpCookie.MaxAge = -1
+		}
+	}
+
+	http.SetCookie(w, httpCookie)
+}
+
+// Reads the expiry of a JWT, without verifying it.
+// The token is verified when it is used, this only sizes the cookie.
+func tokenExpiry(token string) time.Time {
+	claims := &jwt.StandardClaims{}
+	if _, _, err := new(jwt.Parser).ParseUnverified(token, claims); err != nil || claims.ExpiresAt == 0 {
+		return time.Time{}
+	}
+	return time.Unix(claims.ExpiresAt, 0)
 }
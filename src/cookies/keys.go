@@ -0,0 +1,98 @@
+package cookies
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gorilla/securecookie"
+)
+
+// Minimum length of a hash key, as recommended for HMAC-SHA256.
+const minHashKeyLength = 32
+
+// A generation of the keys the cookies are signed and encrypted with.
+type KeyPair struct {
+	HashKey  []byte
+	BlockKey []byte
+}
+
+// Checks the keys are long enough to be safe, and usable by AES.
+func (k KeyPair) Validate() error {
+	if len(k.HashKey) < minHashKeyLength {
+		return fmt.Errorf("cookie hash key must be at least %d bytes, got %d", minHashKeyLength, len(k.HashKey))
+	}
+
+	switch len(k.BlockKey) {
+	case 16, 24, 32:
+		return nil
+	}
+	return fmt.Errorf("cookie block key must be 16, 24 or 32 bytes, got %d", len(k.BlockKey))
+}
+
+// Parses key pairs written as "hashKey:blockKey", both base64 encoded,
+// and separated by commas or new lines. The first pair encodes new
+// cookies, the others are only used to decode cookies set before a rotation.
+// Empty lines and lines starting with "#" are ignored.
+func ParseKeyPairs(raw string) ([]KeyPair, error) {
+	var pairs []KeyPair
+
+	scanner := bufio.NewScanner(strings.NewReader(strings.ReplaceAll(raw, ",", "\n")))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		pair, err := parseKeyPair(line)
+		if err != nil {
+			return nil, fmt.Errorf("cookie key pair %d: %w", len(pairs)+1, err)
+		}
+		pairs = append(pairs, pair)
+	}
+
+	if len(pairs) == 0 {
+		return nil, errors.New("no cookie keys configured")
+	}
+	return pairs, nil
+}
+
+// Reads the key pairs from a file, in the format of ParseKeyPairs.
+func LoadKeyPairs(path string) ([]KeyPair, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading cookie keys: %w", err)
+	}
+	return ParseKeyPairs(string(content))
+}
+
+// Builds a codec per key pair, in the same order.
+func Codecs(pairs []KeyPair) []securecookie.Codec {
+	keys := make([][]byte, 0, len(pairs)*2)
+	for _, pair := range pairs {
+		keys = append(keys, pair.HashKey, pair.BlockKey)
+	}
+	return securecookie.CodecsFromPairs(keys...)
+}
+
+func parseKeyPair(line string) (KeyPair, error) {
+	parts := strings.SplitN(line, ":", 2)
+	if len(parts) != 2 {
+		return KeyPair{}, errors.New(`expected "hashKey:blockKey"`)
+	}
+
+	hashKey, err := base64.StdEncoding.DecodeString(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return KeyPair{}, fmt.Errorf("decoding hash key: %w", err)
+	}
+	blockKey, err := base64.StdEncoding.DecodeString(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return KeyPair{}, fmt.Errorf("decoding block key: %w", err)
+	}
+
+	pair := KeyPair{HashKey: hashKey, BlockKey: blockKey}
+	return pair, pair.Validate()
+}
@@ -0,0 +1,47 @@
+package cookies
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Attributes the token cookies are set with.
+type Policy struct {
+	SameSite http.SameSite
+	Domain   string
+	Path     string
+	// Should only be disabled for local development over plain http.
+	Secure bool
+	// Lifetime of the refresh token cookie. The access token cookie
+	// lives as long as the token inside it.
+	RefreshTTL time.Duration
+}
+
+// Checks that browsers will accept cookies set with the policy.
+func (p Policy) Validate() error {
+	if p.SameSite == http.SameSiteNoneMode && !p.Secure {
+		return fmt.Errorf("cookies with SameSite=None must be secure")
+	}
+	if !strings.HasPrefix(p.Path, "/") {
+		return fmt.Errorf("cookie path %q must start with /", p.Path)
+	}
+	if p.RefreshTTL <= 0 {
+		return fmt.Errorf("refresh cookie ttl must be positive")
+	}
+	return nil
+}
+
+// Reads a SameSite mode: "lax", "strict" or "none".
+func ParseSameSite(mode string) (http.SameSite, error) {
+	switch strings.ToLower(mode) {
+	case "lax":
+		return http.SameSiteLaxMode, nil
+	case "strict":
+		return http.SameSiteStrictMode, nil
+	case "none":
+		return http.SameSiteNoneMode, nil
+	}
+	return http.SameSiteDefaultMode, fmt.Errorf("unknown SameSite mode %q", mode)
+}
@@ -0,0 +1,65 @@
+package cors
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	chicors "github.com/go-chi/cors"
+)
+
+// Applies the policy of the profile, or of the override matching the
+// request path. Rejected preflight requests are logged.
+func Handler(profile Profile, l *slog.Logger) func(next http.Handler) http.Handler {
+	base := newCors(profile.Policy, l)
+
+	overrides := make([]*chicors.Cors, len(profile.Overrides))
+	for i, override := range profile.Overrides {
+		overrides[i] = newCors(override.Policy, l)
+	}
+
+	return func(next http.Handler) http.Handler {
+		baseHandler := base.Handler(next)
+		overrideHandlers := make([]http.Handler, len(overrides))
+		for i, override := range overrides {
+			overrideHandlers[i] = override.Handler(next)
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for i, override := range profile.Overrides {
+				if matchesPrefix(r.URL.Path, override.Prefix) {
+					overrideHandlers[i].ServeHTTP(w, r)
+					return
+				}
+			}
+
+			baseHandler.ServeHTTP(w, r)
+		})
+	}
+}
+
+func newCors(policy Policy, l *slog.Logger) *chicors.Cors {
+	return chicors.New(chicors.Options{
+		AllowOriginFunc: func(r *http.Request, origin string) bool {
+			allowed := policy.Allows(origin)
+			if !allowed && r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				l.WarnContext(r.Context(), "cors preflight rejected",
+					"origin", origin,
+					"method", r.Header.Get("Access-Control-Request-Method"),
+					"path", r.URL.Path,
+				)
+			}
+			return allowed
+		},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
+		ExposedHeaders:   []string{"Link", "WWW-Authenticate", "X-Request-ID", "X-CSRF-Token"},
+		AllowCredentials: policy.AllowCredentials,
+		MaxAge:           300,
+	})
+}
+
+func matchesPrefix(path string, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
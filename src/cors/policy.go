@@ -0,0 +1,207 @@
+package cors
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Origins allowed to call the gateway from a browser.
+type Policy struct {
+	// Exact origins, e.g. "https://app.example.com", or patterns with a
+	// single "*" for a subdomain or a port, e.g. "https://*.example.com"
+	// or "http://localhost:*".
+	AllowedOrigins []string
+	// Lets the browser send the cookies. Never combined with an origin
+	// pattern that matches any host.
+	AllowCredentials bool
+}
+
+// A policy replacing the profile's one under a path prefix.
+type Override struct {
+	Prefix string
+	Policy
+}
+
+// The policy of an environment.
+type Profile struct {
+	Policy
+	// The longest matching prefix wins.
+	Overrides []Override
+}
+
+// Profiles used when no file is configured. Only the development
+// profile allows cross origin calls, from the local frontend.
+func DefaultProfiles() map[string]Profile {
+	return map[string]Profile{
+		"development": {
+			Policy: Policy{
+				AllowedOrigins:   []string{"http://localhost:3000"},
+				AllowCredentials: true,
+			},
+		},
+		"production": {
+			Policy: Policy{AllowCredentials: true},
+		},
+	}
+}
+
+type policyFile struct {
+	AllowedOrigins   []string `json:"allowedOrigins"`
+	AllowCredentials *bool    `json:"allowCredentials"`
+}
+
+type profileFile struct {
+	policyFile
+	Overrides []struct {
+		Prefix string `json:"prefix"`
+		policyFile
+	} `json:"overrides"`
+}
+
+// Reads the profiles from a JSON file, keyed by environment name.
+// Credentials are allowed unless disabled, and overrides inherit the
+// setting of their profile.
+func LoadProfiles(path string) (map[string]Profile, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading cors profiles: %w", err)
+	}
+
+	document := struct {
+		Profiles map[string]profileFile `json:"profiles"`
+	}{}
+	if err := json.Unmarshal(content, &document); err != nil {
+		return nil, fmt.Errorf("decoding cors profiles: %w", err)
+	}
+
+	profiles := map[string]Profile{}
+	for name, file := range document.Profiles {
+		profile := Profile{Policy: file.policy(true)}
+		for _, override := range file.Overrides {
+			profile.Overrides = append(profile.Overrides, Override{
+				Prefix: override.Prefix,
+				Policy: override.policy(profile.AllowCredentials),
+			})
+		}
+		profiles[name] = profile
+	}
+	return profiles, nil
+}
+
+func (p policyFile) policy(defaultCredentials bool) Policy {
+	policy := Policy{
+		AllowedOrigins:   p.AllowedOrigins,
+		AllowCredentials: defaultCredentials,
+	}
+	if p.AllowCredentials != nil {
+		policy.AllowCredentials = *p.AllowCredentials
+	}
+	return policy
+}
+
+// Checks the origins and prefixes, and sorts the overrides by prefix.
+func (p *Profile) Validate() error {
+	if err := p.Policy.Validate(); err != nil {
+		return err
+	}
+
+	for _, override := range p.Overrides {
+		if !strings.HasPrefix(override.Prefix, "/") {
+			return fmt.Errorf("cors override %q: prefix must start with /", override.Prefix)
+		}
+		if err := override.Policy.Validate(); err != nil {
+			return fmt.Errorf("cors override %q: %w", override.Prefix, err)
+		}
+	}
+
+	sort.SliceStable(p.Overrides, func(i, j int) bool {
+		return len(p.Overrides[i].Prefix) > len(p.Overrides[j].Prefix)
+	})
+	return nil
+}
+
+// Checks every origin is an exact origin or a supported pattern.
+func (p Policy) Validate() error {
+	for _, origin := range p.AllowedOrigins {
+		if !strings.HasPrefix(origin, "http://") && !strings.HasPrefix(origin, "https://") {
+			return fmt.Errorf("cors origin %q must start with http:// or https://", origin)
+		}
+
+		host := origin[strings.Index(origin, "://")+3:]
+		if host == "" || strings.Contains(host, "/") {
+			return fmt.Errorf("cors origin %q must be a scheme and a host, without a path", origin)
+		}
+
+		switch strings.Count(host, "*") {
+		case 0:
+		case 1:
+			if host == "*" && p.AllowCredentials {
+				return fmt.Errorf("cors origin %q would allow any site to use the cookies", origin)
+			}
+			if !strings.HasPrefix(host, "*.") && !strings.HasSuffix(host, ":*") && host != "*" {
+				return fmt.Errorf("cors origin %q: the wildcard can only be a subdomain or a port", origin)
+			}
+		default:
+			return fmt.Errorf("cors origin %q has more than one wildcard", origin)
+		}
+	}
+	return nil
+}
+
+// Tells if the origin is allowed.
+func (p Policy) Allows(origin string) bool {
+	origin = strings.ToLower(origin)
+
+	for _, allowed := range p.AllowedOrigins {
+		allowed = strings.ToLower(allowed)
+
+		wildcard := strings.Index(allowed, "*")
+		if wildcard < 0 {
+			if origin == allowed {
+				return true
+			}
+			continue
+		}
+
+		prefix, suffix := allowed[:wildcard], allowed[wildcard+1:]
+		if len(origin) <= len(prefix)+len(suffix) || !strings.HasPrefix(origin, prefix) || !strings.HasSuffix(origin, suffix) {
+			continue
+		}
+		matched := origin[len(prefix) : len(origin)-len(suffix)]
+		if strings.HasSuffix(prefix, ":") && suffix == "" {
+			if isPort(matched) {
+				return true
+			}
+			continue
+		}
+		if isHostPart(matched) {
+			return true
+		}
+	}
+	return false
+}
+
+// Tells if the text matched by a port wildcard is a port, so that
+// "http://localhost:*" can't match "http://localhost:3000.evil.com".
+func isPort(value string) bool {
+	for _, c := range value {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Tells if the text matched by a wildcard stays within the host, so
+// that "https://*.example.com" can't match "https://evil.com/.example.com".
+func isHostPart(value string) bool {
+	for _, c := range value {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,89 @@
+package cors
+
+import "testing"
+
+func TestPolicyAllows(t *testing.T) {
+	policy := Policy{
+		AllowedOrigins: []string{
+			"https://app.example.com",
+			"https://*.example.org",
+			"http://localhost:*",
+		},
+	}
+
+	tests := []struct {
+		name   string
+		origin string
+		want   bool
+	}{
+		{"exact origin", "https://app.example.com", true},
+		{"exact origin ignores case", "https://APP.Example.com", true},
+		{"exact origin other scheme", "http://app.example.com", false},
+		{"exact origin other port", "https://app.example.com:8443", false},
+		{"exact origin as subdomain", "https://evil.app.example.com", false},
+
+		{"subdomain wildcard", "https://app.example.org", true},
+		{"subdomain wildcard nested", "https://a.b.example.org", true},
+		{"subdomain wildcard without subdomain", "https://example.org", false},
+		{"subdomain wildcard empty subdomain", "https://.example.org", false},
+		{"subdomain wildcard other scheme", "http://app.example.org", false},
+		{"subdomain wildcard path injection", "https://evil.com/.example.org", false},
+		{"subdomain wildcard query injection", "https://evil.com?.example.org", false},
+		{"subdomain wildcard fragment injection", "https://evil.com#.example.org", false},
+		{"subdomain wildcard userinfo injection", "https://evil.com@x.example.org", false},
+		{"subdomain wildcard suffix of other host", "https://app.example.org.evil.com", false},
+		{"subdomain wildcard lookalike host", "https://evilexample.org", false},
+
+		{"port wildcard", "http://localhost:3000", true},
+		{"port wildcard other port", "http://localhost:8080", true},
+		{"port wildcard without port", "http://localhost", false},
+		{"port wildcard empty port", "http://localhost:", false},
+		{"port wildcard other scheme", "https://localhost:3000", false},
+		{"port wildcard host injection", "http://localhost:3000.evil.com", false},
+		{"port wildcard path injection", "http://localhost:3000/evil", false},
+
+		{"empty origin", "", false},
+		{"null origin", "null", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.Allows(tt.origin); got != tt.want {
+				t.Errorf("Allows(%q) = %v, want %v", tt.origin, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		origin      string
+		credentials bool
+		wantErr     bool
+	}{
+		{"exact origin", "https://app.example.com", true, false},
+		{"subdomain wildcard", "https://*.example.com", true, false},
+		{"port wildcard", "http://localhost:*", true, false},
+		{"any host without credentials", "https://*", false, false},
+		{"any host with credentials", "https://*", true, true},
+		{"missing scheme", "app.example.com", false, true},
+		{"other scheme", "ftp://app.example.com", false, true},
+		{"missing host", "https://", false, true},
+		{"path", "https://app.example.com/path", false, true},
+		{"wildcard in the middle", "https://app.*.com", false, true},
+		{"partial subdomain wildcard", "https://app-*.example.com", false, true},
+		{"two wildcards", "https://*.example.com:*", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := Policy{AllowedOrigins: []string{tt.origin}, AllowCredentials: tt.credentials}
+
+			err := policy.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() with %q = %v, want error %v", tt.origin, err, tt.wantErr)
+			}
+		})
+	}
+}
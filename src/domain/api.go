@@ -6,4 +6,9 @@ type CookieHandler interface {
 	GetAccessToken(r *http.Request) string
 	GetRefreshToken(r *http.Request) string
 	GenerateCookiesFromTokens(w http.ResponseWriter, accessToken string, refreshToken string)
+	ClearTokenCookies(w http.ResponseWriter)
+	GetCSRFToken(r *http.Request) string
+	SetCSRFCookie(w http.ResponseWriter, token string)
+	// Stores a new random CSRF token on the cookie, and returns it.
+	IssueCSRFToken(w http.ResponseWriter) (string, error)
 }
@@ -0,0 +1,15 @@
+package domain
+
+import "net/http"
+
+// Header the CSRF token is sent on, by the client on state changing
+// requests and by the gateway when it rotates the token on login.
+const CSRFTokenHeader = "X-CSRF-Token"
+
+type CsrfTokenResponse struct {
+	CsrfToken string `json:"csrfToken"`
+}
+
+type CsrfHttpHandler interface {
+	IssueToken(w http.ResponseWriter, r *http.Request)
+}
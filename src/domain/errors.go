@@ -1,7 +1,60 @@
 package domain
 
-import "errors"
+import (
+	"errors"
+	"strings"
+)
 
 var (
-	ErrInvalidToken = errors.New("invalid jwt token")
+	ErrInvalidToken       = errors.New("invalid jwt token")
+	ErrInvalidRequestBody = errors.New("invalid request body")
+)
+
+// Machine readable error codes returned on the error body.
+const (
+	ErrCodeInvalidRequest     = "invalid_request"
+	ErrCodeValidationFailed   = "validation_failed"
+	ErrCodeInvalidToken       = "invalid_token"
+	ErrCodeExpiredToken       = "expired_token"
+	ErrCodeRevokedToken       = "revoked_token"
+	ErrCodeMissingToken       = "missing_token"
+	ErrCodeInsufficientRole   = "insufficient_role"
+	ErrCodeInvalidCSRFToken   = "invalid_csrf_token"
+	ErrCodeInvalidCredentials = "invalid_credentials"
+	ErrCodeUnauthenticated    = "unauthenticated"
+	ErrCodePermissionDenied   = "permission_denied"
+	ErrCodeNotFound           = "not_found"
+	ErrCodeAlreadyExists      = "already_exists"
+	ErrCodeNotImplemented     = "not_implemented"
+	ErrCodeUnavailable        = "unavailable"
+	ErrCodeBadGateway         = "bad_gateway"
+	ErrCodeTimeout            = "timeout"
+	ErrCodeInternal           = "internal_error"
 )
+
+// Error body shared by every route of the gateway.
+type ErrorResponse struct {
+	Code      string      `json:"code"`
+	Message   string      `json:"message"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestId string      `json:"requestId,omitempty"`
+}
+
+// Describes why a single field of a request failed validation.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// Returned when a request doesn't pass validation.
+type ValidationErrors []FieldError
+
+func (ve ValidationErrors) Error() string {
+	messages := make([]string, len(ve))
+	for i, fe := range ve {
+		messages[i] = fe.Message
+	}
+	return strings.Join(messages, "; ")
+}
@@ -0,0 +1,20 @@
+package domain
+
+// Outcomes recorded by the auth metrics.
+const (
+	AuthResultSuccess  = "success"
+	AuthResultRejected = "rejected"
+	AuthResultError    = "error"
+)
+
+// Records the authentication events.
+type AuthMetrics interface {
+	// A login, by result.
+	LoginAttempted(result string)
+	// A refresh of an expired session, made while authorizing a request.
+	TokenRefreshed(result string)
+	// A token that failed to parse, e.g. "expired" or "invalid".
+	TokenParseFailed(reason string)
+	// An authenticated request denied because of its role.
+	RoleDenied(role string)
+}
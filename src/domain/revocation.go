@@ -0,0 +1,15 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Keeps the IDs ("jti") of the access tokens revoked before they expire.
+// Implementations may share the list between replicas.
+type RevocationStore interface {
+	// Revokes the token until it expires on its own. A zero expiresAt
+	// revokes it for good.
+	Revoke(ctx context.Context, tokenId string, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, tokenId string) (bool, error)
+}
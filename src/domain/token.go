@@ -1,7 +1,10 @@
 package domain
 
 import (
+	"context"
+
 	"github.com/golang-jwt/jwt"
+	"github.com/plagioriginal/api-gateway/auth"
 )
 
 type TokenResponse struct {
@@ -16,6 +19,10 @@ type TokenManager interface {
 	// @todo: replace `interface{}` with proper JWT Object
 	ParseToken(tokenString string) (*jwt.Token, error)
 	IsTokenValid(token *jwt.Token) bool
-	GetTokenRole(token *jwt.Token) (string, error)
-	GetTokenIssuer(token *jwt.Token) (string, error)
+	GetPrincipal(token *jwt.Token) (auth.Principal, error)
+}
+
+// Exchanges refresh tokens for new token pairs with the users service.
+type TokenRefresher interface {
+	Refresh(ctx context.Context, refreshToken string) (TokenResponse, error)
 }
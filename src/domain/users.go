@@ -20,7 +20,7 @@ type User struct {
 }
 
 type RefreshRequest struct {
-	RefreshToken string `json:"refreshToken"`
+	RefreshToken string `validate:"required" json:"refreshToken"`
 }
 
 type LoginRequest struct {
@@ -30,9 +30,10 @@ type LoginRequest struct {
 
 type AddUserRequest struct {
 	Username string `validate:"required,min=3" json:"username"`
-	Password string `validate:"required,min=3" json:"password"`
+	Password string `validate:"required,min=8" json:"password"`
 	Role     string `validate:"required" json:"role"`
-	JwtToken string `validate:"required" json:"token"`
+	// Filled from the authenticated request, never from the body.
+	JwtToken string `validate:"required" json:"-"`
 }
 
 // Services to be used by the HTTP Handler.
@@ -49,4 +50,5 @@ type UsersHttpHandler interface {
 	Login(w http.ResponseWriter, r *http.Request)
 	RefreshJWT(w http.ResponseWriter, r *http.Request)
 	AddUser(w http.ResponseWriter, r *http.Request)
+	GetMe(w http.ResponseWriter, r *http.Request)
 }
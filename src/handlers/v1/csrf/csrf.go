@@ -0,0 +1,43 @@
+package csrf
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/plagioriginal/api-gateway/domain"
+	"github.com/plagioriginal/api-gateway/helpers"
+)
+
+type CsrfHandler struct {
+	Logger        *slog.Logger
+	CookieHandler domain.CookieHandler
+}
+
+func New(cookieHandler domain.CookieHandler, l *slog.Logger) domain.CsrfHttpHandler {
+	return CsrfHandler{
+		CookieHandler: cookieHandler,
+		Logger:        l,
+	}
+}
+
+// Issues the token to send on the X-CSRF-Token header.
+// The token the browser already holds is reused, so other open tabs
+// keep working. It is replaced on login and deleted on logout, so it
+// doesn't outlive the session.
+func (ch CsrfHandler) IssueToken(w http.ResponseWriter, r *http.Request) {
+	token := ch.CookieHandler.GetCSRFToken(r)
+	if token != "" {
+		ch.CookieHandler.SetCSRFCookie(w, token)
+	} else {
+		var err error
+		if token, err = ch.CookieHandler.IssueCSRFToken(w); err != nil {
+			ch.Logger.ErrorContext(r.Context(), "couldn't issue a csrf token", "error", err)
+			helpers.Error(w, r, http.StatusInternalServerError, domain.ErrCodeInternal, "couldn't generate a csrf token", nil)
+			return
+		}
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	helpers.JSON(w, r, domain.CsrfTokenResponse{CsrfToken: token})
+}
@@ -1,93 +1,110 @@
 package users
 
 import (
-	"context"
-	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
 
-	"github.com/go-playground/validator/v10"
+	"github.com/plagioriginal/api-gateway/auth"
 	"github.com/plagioriginal/api-gateway/domain"
 	"github.com/plagioriginal/api-gateway/helpers"
+	"github.com/plagioriginal/api-gateway/middlewares"
+	"github.com/plagioriginal/api-gateway/validation"
+	"google.golang.org/grpc/codes"
 )
 
 type UsersHandler struct {
-	Logger        *log.Logger
-	Validator     *validator.Validate
-	UsersClient   domain.UsersClient
-	CookieHandler domain.CookieHandler
+	Logger          *slog.Logger
+	Validator       *validation.Validator
+	UsersClient     domain.UsersClient
+	CookieHandler   domain.CookieHandler
+	TokenManager    domain.TokenManager
+	RevocationStore domain.RevocationStore
+	AuthMetrics     domain.AuthMetrics
+	// Shared with the authorization middleware, so explicit refreshes
+	// are coalesced with the ones of expired sessions.
+	TokenRefresher domain.TokenRefresher
+	// Where the access token revoked on logout is read from, the same
+	// as for the authenticated routes.
+	TokenExtractors []middlewares.TokenExtractor
 }
 
 func New(
 	usersClient domain.UsersClient,
+	tokenRefresher domain.TokenRefresher,
 	cookieHandler domain.CookieHandler,
-	v *validator.Validate,
-	l *log.Logger,
+	tokenManager domain.TokenManager,
+	revocationStore domain.RevocationStore,
+	authMetrics domain.AuthMetrics,
+	tokenExtractors []middlewares.TokenExtractor,
+	v *validation.Validator,
+	l *slog.Logger,
 ) domain.UsersHttpHandler {
 	return UsersHandler{
-		UsersClient:   usersClient,
-		CookieHandler: cookieHandler,
-		Logger:        l,
-		Validator:     v,
+		UsersClient:     usersClient,
+		TokenRefresher:  tokenRefresher,
+		CookieHandler:   cookieHandler,
+		TokenManager:    tokenManager,
+		RevocationStore: revocationStore,
+		AuthMetrics:     authMetrics,
+		TokenExtractors: tokenExtractors,
+		Logger:          l,
+		Validator:       v,
 	}
 }
 
+// Ends the session. The cookies are always cleared, even when the
+// users service can't be told about it, so the user is logged out here.
 func (uh UsersHandler) Logout(w http.ResponseWriter, r *http.Request) {
-	ctx := context.Background()
+	ctx := r.Context()
 	refreshToken := uh.CookieHandler.GetRefreshToken(r)
 
-	if len(refreshToken) == 0 {
-		w.WriteHeader(http.StatusBadRequest)
-		helpers.JSON(w, r, "invalid token")
-		return
-	}
+	uh.revokeAccessToken(r)
+	uh.CookieHandler.ClearTokenCookies(w)
 
-	response, err := uh.UsersClient.Logout(ctx, refreshToken)
-
-	if err != nil {
-		uh.Logger.Printf("error on logout %v\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		helpers.JSON(w, r, "internal error")
-		return
+	if len(refreshToken) > 0 {
+		if _, err := uh.UsersClient.Logout(ctx, refreshToken); err != nil {
+			uh.Logger.WarnContext(r.Context(), "error on users client upon logout", "error", err)
+		}
 	}
 
-	uh.CookieHandler.GenerateCookiesFromTokens(w, "", "")
-
-	w.WriteHeader(http.StatusOK)
-	helpers.JSON(w, r, response)
+	w.WriteHeader(http.StatusNoContent)
 }
 
 func (uh UsersHandler) Login(w http.ResponseWriter, r *http.Request) {
-	ctx := context.Background()
+	ctx := r.Context()
 
 	request := domain.LoginRequest{}
-	err := json.NewDecoder(r.Body).Decode(&request)
-
-	if err != nil {
-		uh.Logger.Printf("login request body error: %v\n", err)
-		w.WriteHeader(http.StatusBadRequest)
-		helpers.JSON(w, r, "invalid request")
-		return
-	}
-
-	err = uh.Validator.Struct(request)
-	if err != nil {
-		validationErrors := err.(validator.ValidationErrors).Error()
-		w.WriteHeader(http.StatusBadRequest)
-		helpers.JSON(w, r, validationErrors)
+	if err := uh.Validator.DecodeAndValidate(r, &request); err != nil {
+		helpers.RequestError(w, r, err)
 		return
 	}
 
 	result, err := uh.UsersClient.Login(ctx, request)
 	if err != nil {
-		uh.Logger.Printf("error on client upon login: %v\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		helpers.JSON(w, r, "internal error")
+		// Don't tell apart unknown usernames from wrong passwords.
+		switch helpers.GrpcStatus(err).Code() {
+		case codes.Unauthenticated, codes.NotFound, codes.InvalidArgument, codes.PermissionDenied:
+			uh.AuthMetrics.LoginAttempted(domain.AuthResultRejected)
+			helpers.Error(w, r, http.StatusUnauthorized, domain.ErrCodeInvalidCredentials, "invalid username or password", nil)
+		default:
+			uh.AuthMetrics.LoginAttempted(domain.AuthResultError)
+			uh.writeClientError(w, r, err)
+		}
 		return
 	}
+	uh.AuthMetrics.LoginAttempted(domain.AuthResultSuccess)
 
 	uh.CookieHandler.GenerateCookiesFromTokens(w, result.AccessToken, result.RefreshToken)
 
+	// A new session gets a new CSRF token, so one planted before the
+	// login can't be used with it.
+	csrfToken, err := uh.CookieHandler.IssueCSRFToken(w)
+	if err != nil {
+		uh.Logger.ErrorContext(r.Context(), "couldn't rotate the csrf token", "error", err)
+	} else {
+		w.Header().Set(domain.CSRFTokenHeader, csrfToken)
+	}
+
 	result.AccessToken = ""
 	result.RefreshToken = ""
 
@@ -97,12 +114,11 @@ func (uh UsersHandler) Login(w http.ResponseWriter, r *http.Request) {
 }
 
 func (uh UsersHandler) RefreshJWT(w http.ResponseWriter, r *http.Request) {
-	ctx := context.Background()
+	ctx := r.Context()
 
 	refreshToken := uh.CookieHandler.GetRefreshToken(r)
 	if len(refreshToken) == 0 {
-		w.WriteHeader(http.StatusNotFound)
-		helpers.JSON(w, r, "invalid token")
+		helpers.Error(w, r, http.StatusUnauthorized, domain.ErrCodeInvalidToken, "missing refresh token", nil)
 		return
 	}
 
@@ -110,20 +126,14 @@ func (uh UsersHandler) RefreshJWT(w http.ResponseWriter, r *http.Request) {
 		RefreshToken: refreshToken,
 	}
 
-	err := uh.Validator.Var(request.RefreshToken, "required")
-
-	if err != nil {
-		validationErrors := err.(validator.ValidationErrors)
-		w.WriteHeader(http.StatusBadRequest)
-		helpers.JSON(w, r, validationErrors)
+	if err := uh.Validator.Validate(request); err != nil {
+		helpers.RequestError(w, r, err)
 		return
 	}
 
-	result, err := uh.UsersClient.RefreshJWT(ctx, request.RefreshToken)
+	result, err := uh.TokenRefresher.Refresh(ctx, request.RefreshToken)
 	if err != nil {
-		uh.Logger.Printf("error on client upon refresh: %v\n", err)
-		w.WriteHeader(http.StatusNotFound)
-		helpers.JSON(w, r, "error still don't check what it is (code)")
+		uh.writeClientError(w, r, err)
 		return
 	}
 
@@ -139,15 +149,79 @@ func (uh UsersHandler) RefreshJWT(w http.ResponseWriter, r *http.Request) {
 func (uh UsersHandler) AddUser(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	userId := ctx.Value("userId")
-	userRole := ctx.Value("userRole")
+	// The upstream service authorizes the creation with the caller's token.
+	request := domain.AddUserRequest{}
+	request.JwtToken = auth.AccessTokenFromContext(ctx)
+
+	if err := uh.Validator.DecodeAndValidate(r, &request); err != nil {
+		helpers.RequestError(w, r, err)
+		return
+	}
 
-	uh.Logger.Println(userId)
-	uh.Logger.Println(userRole)
+	user, err := uh.UsersClient.AddUser(ctx, request)
+	if err != nil {
+		uh.writeClientError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	helpers.JSON(w, r, user)
+}
+
+// Returns the authenticated user, as read from its token.
+// The users service has no lookup to get the rest of the profile from.
+func (uh UsersHandler) GetMe(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.PrincipalFromContext(r.Context())
+
+	user := domain.User{
+		Id:       principal.UserID,
+		Username: principal.Username,
+		Role: domain.Role{
+			RoleSlug:  principal.RoleSlug,
+			RoleLabel: principal.RoleLabel,
+		},
+	}
+
+	w.WriteHeader(http.StatusOK)
+	helpers.JSON(w, r, user)
+}
+
+// Revokes the access token of the caller, be it the one of the cookie
+// session or a bearer token, so copies of it stop working before it
+// expires.
+func (uh UsersHandler) revokeAccessToken(r *http.Request) {
+	tokenString := ""
+	for _, extractor := range uh.TokenExtractors {
+		if tokenString = extractor.Extract(r); tokenString != "" {
+			break
+		}
+	}
+	if tokenString == "" {
+		return
+	}
+
+	token, err := uh.TokenManager.ParseToken(tokenString)
+	if err != nil {
+		// Expired or invalid tokens are already rejected.
+		return
+	}
+
+	principal, err := uh.TokenManager.GetPrincipal(token)
+	if err != nil || principal.TokenID == "" {
+		uh.Logger.WarnContext(r.Context(), "access token can't be revoked, it has no jti")
+		return
+	}
+
+	if err := uh.RevocationStore.Revoke(r.Context(), principal.TokenID, principal.ExpiresAt); err != nil {
+		uh.Logger.ErrorContext(r.Context(), "error revoking the access token", "error", err)
+	}
+}
 
-	response := map[string]string{
-		"hello": "world",
+// Writes an error coming from the users client into the response.
+func (uh UsersHandler) writeClientError(w http.ResponseWriter, r *http.Request, err error) {
+	if httpStatus, _ := helpers.GrpcErrorStatus(err); httpStatus >= http.StatusInternalServerError {
+		uh.Logger.ErrorContext(r.Context(), "error on users client", "error", err)
 	}
 
-	helpers.JSON(w, r, response)
+	helpers.GrpcError(w, r, err)
 }
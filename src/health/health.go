@@ -0,0 +1,159 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/plagioriginal/api-gateway/helpers"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	StatusUp    = "up"
+	StatusDown  = "down"
+	StatusReady = "ready"
+	// Not ready because the dependencies aren't.
+	StatusNotReady = "not_ready"
+	// Not ready because the gateway is shutting down.
+	StatusDraining = "draining"
+)
+
+// A gRPC service the gateway can't work without.
+type Dependency struct {
+	Name string
+	Conn *grpc.ClientConn
+}
+
+type DependencyReport struct {
+	Status string `json:"status"`
+	// Connectivity state of the connection, e.g. "READY".
+	State  string `json:"state"`
+	Detail string `json:"detail,omitempty"`
+}
+
+type Report struct {
+	Status       string                      `json:"status"`
+	Dependencies map[string]DependencyReport `json:"dependencies,omitempty"`
+}
+
+// Answers the liveness and readiness probes.
+type Checker struct {
+	dependencies []Dependency
+	timeout      time.Duration
+	draining     int32
+}
+
+// Returns a new checker. Each dependency is given timeout to answer
+// the health check.
+func New(timeout time.Duration, dependencies ...Dependency) *Checker {
+	return &Checker{
+		dependencies: dependencies,
+		timeout:      timeout,
+	}
+}
+
+// Marks the gateway as not ready, so load balancers stop sending
+// traffic before the server shuts down.
+func (c *Checker) Drain() {
+	atomic.StoreInt32(&c.draining, 1)
+}
+
+// The process is up. Doesn't check the dependencies, so an upstream
+// outage doesn't get the gateway restarted.
+func (c *Checker) Liveness(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	helpers.JSON(w, r, Report{Status: StatusUp})
+}
+
+// Ready when every dependency is serving, with a report per dependency.
+func (c *Checker) Readiness(w http.ResponseWriter, r *http.Request) {
+	if atomic.LoadInt32(&c.draining) == 1 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		helpers.JSON(w, r, Report{Status: StatusDraining})
+		return
+	}
+
+	report := c.check(r.Context())
+
+	httpStatus := http.StatusOK
+	if report.Status != StatusReady {
+		httpStatus = http.StatusServiceUnavailable
+	}
+
+	w.WriteHeader(httpStatus)
+	helpers.JSON(w, r, report)
+}
+
+// Checks the dependencies concurrently.
+func (c *Checker) check(ctx context.Context) Report {
+	reports := make([]DependencyReport, len(c.dependencies))
+
+	wg := sync.WaitGroup{}
+	for i, dependency := range c.dependencies {
+		wg.Add(1)
+		go func(i int, dependency Dependency) {
+			defer wg.Done()
+			reports[i] = c.checkDependency(ctx, dependency.Conn)
+		}(i, dependency)
+	}
+	wg.Wait()
+
+	report := Report{
+		Status:       StatusReady,
+		Dependencies: map[string]DependencyReport{},
+	}
+	for i, dependency := range c.dependencies {
+		report.Dependencies[dependency.Name] = reports[i]
+		if reports[i].Status != StatusUp {
+			report.Status = StatusNotReady
+		}
+	}
+	return report
+}
+
+// Checks the state of the connection, then asks the service through
+// the standard gRPC health checking protocol.
+func (c *Checker) checkDependency(ctx context.Context, conn *grpc.ClientConn) DependencyReport {
+	state := conn.GetState()
+	report := DependencyReport{State: state.String()}
+
+	switch state {
+	case connectivity.Idle:
+		// Idle connections only connect on the next call.
+		conn.Connect()
+	case connectivity.TransientFailure, connectivity.Shutdown:
+		report.Status = StatusDown
+		report.Detail = "connection is not usable"
+		return report
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	response, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	switch {
+	case status.Code(err) == codes.Unimplemented:
+		// The service doesn't expose the health protocol, reaching it is
+		// all that can be checked.
+		report.Status = StatusUp
+		report.Detail = "health checking protocol not implemented"
+	case err != nil:
+		report.Status = StatusDown
+		report.Detail = status.Convert(err).Message()
+	case response.GetStatus() != healthpb.HealthCheckResponse_SERVING:
+		report.Status = StatusDown
+		report.Detail = response.GetStatus().String()
+	default:
+		report.Status = StatusUp
+	}
+
+	report.State = conn.GetState().String()
+	return report
+}
@@ -0,0 +1,87 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/plagioriginal/api-gateway/domain"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// HTTP status and error code for each of the gRPC codes we translate.
+// Anything not listed here is treated as an internal error.
+var grpcCodesToHttp = map[codes.Code]struct {
+	status int
+	code   string
+}{
+	codes.Unauthenticated:  {http.StatusUnauthorized, domain.ErrCodeUnauthenticated},
+	codes.PermissionDenied: {http.StatusForbidden, domain.ErrCodePermissionDenied},
+	codes.NotFound:         {http.StatusNotFound, domain.ErrCodeNotFound},
+	codes.InvalidArgument:  {http.StatusBadRequest, domain.ErrCodeInvalidRequest},
+	codes.AlreadyExists:    {http.StatusConflict, domain.ErrCodeAlreadyExists},
+	codes.DeadlineExceeded: {http.StatusGatewayTimeout, domain.ErrCodeTimeout},
+	codes.Unavailable:      {http.StatusServiceUnavailable, domain.ErrCodeUnavailable},
+	codes.Unimplemented:    {http.StatusNotImplemented, domain.ErrCodeNotImplemented},
+}
+
+// Error writes the standard error body with the given http status.
+func Error(w http.ResponseWriter, r *http.Request, httpStatus int, code string, message string, details interface{}) {
+	w.WriteHeader(httpStatus)
+	JSON(w, r, domain.ErrorResponse{
+		Code:      code,
+		Message:   message,
+		Details:   details,
+		RequestId: middleware.GetReqID(r.Context()),
+	})
+}
+
+// GrpcError translates an error returned by one of the gRPC clients
+// into the matching http status and error body.
+// Messages of server side failures are not exposed to the caller.
+func GrpcError(w http.ResponseWriter, r *http.Request, err error) {
+	httpStatus, code := GrpcErrorStatus(err)
+
+	message := http.StatusText(httpStatus)
+	if httpStatus < http.StatusInternalServerError {
+		message = GrpcStatus(err).Message()
+	}
+
+	Error(w, r, httpStatus, code, message, nil)
+}
+
+// GrpcErrorStatus returns the http status and error code for an error
+// returned by one of the gRPC clients.
+func GrpcErrorStatus(err error) (int, string) {
+	if mapped, ok := grpcCodesToHttp[GrpcStatus(err).Code()]; ok {
+		return mapped.status, mapped.code
+	}
+	return http.StatusInternalServerError, domain.ErrCodeInternal
+}
+
+// GrpcStatus extracts the gRPC status of an error, also accounting for
+// context errors raised before the call reached the server.
+func GrpcStatus(err error) *status.Status {
+	if s, ok := status.FromError(err); ok {
+		return s
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return status.FromContextError(err)
+	}
+	return status.New(codes.Unknown, err.Error())
+}
+
+// RequestError writes the response for a request that failed decoding
+// or validation, listing the failing fields when there are any.
+func RequestError(w http.ResponseWriter, r *http.Request, err error) {
+	var validationErrors domain.ValidationErrors
+	if errors.As(err, &validationErrors) {
+		Error(w, r, http.StatusBadRequest, domain.ErrCodeValidationFailed, "validation failed", validationErrors)
+		return
+	}
+
+	Error(w, r, http.StatusBadRequest, domain.ErrCodeInvalidRequest, domain.ErrInvalidRequestBody.Error(), nil)
+}
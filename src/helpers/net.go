@@ -0,0 +1,21 @@
+package helpers
+
+import (
+	"net/netip"
+	"strings"
+)
+
+// ParsePrefix reads a CIDR range like "10.0.0.0/8". A single address is
+// read as the range holding only itself.
+func ParsePrefix(raw string) (netip.Prefix, error) {
+	if strings.Contains(raw, "/") {
+		prefix, err := netip.ParsePrefix(raw)
+		return prefix.Masked(), err
+	}
+
+	addr, err := netip.ParseAddr(raw)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
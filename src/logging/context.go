@@ -0,0 +1,57 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/plagioriginal/api-gateway/auth"
+)
+
+type contextKey int
+
+const requestFieldsKey contextKey = iota
+
+// Fields of a request only known once it went down the middlewares,
+// kept so the request log line has them too.
+type requestFields struct {
+	userID string
+}
+
+func withRequestFields(ctx context.Context) (context.Context, *requestFields) {
+	fields := &requestFields{}
+	return context.WithValue(ctx, requestFieldsKey, fields), fields
+}
+
+// Records the user a request was authenticated as.
+func SetUserID(ctx context.Context, userID string) {
+	if fields, ok := ctx.Value(requestFieldsKey).(*requestFields); ok {
+		fields.userID = userID
+	}
+}
+
+// The request ID, user ID and route of the request in ctx, when known.
+func requestAttrs(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+
+	attrs := []slog.Attr{}
+	if requestID := middleware.GetReqID(ctx); requestID != "" {
+		attrs = append(attrs, slog.String("request_id", requestID))
+	}
+
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		attrs = append(attrs, slog.String("user_id", principal.UserID))
+	} else if fields, ok := ctx.Value(requestFieldsKey).(*requestFields); ok && fields.userID != "" {
+		attrs = append(attrs, slog.String("user_id", fields.userID))
+	}
+
+	if routeContext := chi.RouteContext(ctx); routeContext != nil {
+		if pattern := routeContext.RoutePattern(); pattern != "" {
+			attrs = append(attrs, slog.String("route", pattern))
+		}
+	}
+	return attrs
+}
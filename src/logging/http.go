@@ -0,0 +1,87 @@
+package logging
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/plagioriginal/api-gateway/domain"
+	"github.com/plagioriginal/api-gateway/helpers"
+)
+
+// Logs a line per request once it is handled. Only the path is logged,
+// since query strings may hold tokens. Requests to quietPaths, like the
+// health checks, are logged at debug level.
+func RequestLogger(l *slog.Logger, quietPaths ...string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			ctx, _ := withRequestFields(r.Context())
+			r = r.WithContext(ctx)
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			level := slog.LevelInfo
+			switch {
+			case status >= http.StatusInternalServerError:
+				level = slog.LevelError
+			case helpers.InArray(r.URL.Path, quietPaths):
+				level = slog.LevelDebug
+			}
+
+			l.LogAttrs(ctx, level, "request",
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", status),
+				slog.Int("bytes", ww.BytesWritten()),
+				slog.Duration("duration", time.Since(start)),
+				slog.String("remote_addr", r.RemoteAddr),
+			)
+		})
+	}
+}
+
+type levelBody struct {
+	Level string `json:"level"`
+}
+
+// Reads the log level on GET, and changes it on PUT with a body like
+// {"level": "debug"}. Meant for the internal listener only.
+func LevelHandler(level *slog.LevelVar, l *slog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			body := levelBody{}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				helpers.Error(w, r, http.StatusBadRequest, domain.ErrCodeInvalidRequest, "expected a body like {\"level\": \"debug\"}", nil)
+				return
+			}
+
+			var newLevel slog.Level
+			if err := newLevel.UnmarshalText([]byte(body.Level)); err != nil {
+				helpers.Error(w, r, http.StatusBadRequest, domain.ErrCodeInvalidRequest, "level must be debug, info, warn or error", nil)
+				return
+			}
+
+			l.Info("log level changed", "from", level.Level().String(), "to", newLevel.String())
+			level.Set(newLevel)
+		default:
+			helpers.Error(w, r, http.StatusMethodNotAllowed, domain.ErrCodeInvalidRequest, "method not allowed", nil)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		helpers.JSON(w, r, levelBody{Level: level.Level().String()})
+	})
+}
@@ -0,0 +1,62 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/plagioriginal/api-gateway/config"
+)
+
+// Returns the logger of the gateway, writing to w, and the level it
+// logs at, which can be changed while running.
+// Every line is redacted, and carries the request ID, user ID and route
+// of the context it was logged with.
+func New(cfg config.Logging, w io.Writer) (*slog.Logger, *slog.LevelVar, error) {
+	level := &slog.LevelVar{}
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		return nil, nil, fmt.Errorf("invalid log level %q", cfg.Level)
+	}
+
+	options := &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redactAttr,
+	}
+
+	var handler slog.Handler
+	switch strings.ToLower(cfg.Format) {
+	case "text":
+		handler = slog.NewTextHandler(w, options)
+	default:
+		handler = slog.NewJSONHandler(w, options)
+	}
+
+	return slog.New(contextHandler{handler}), level, nil
+}
+
+// Logs the error and exits, for the errors the gateway can't start with.
+func Fatal(l *slog.Logger, msg string, err error) {
+	l.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// Adds the request attributes found in the context to each record.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	record.AddAttrs(requestAttrs(ctx)...)
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,119 @@
+package logging
+
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// Parts of attribute keys whose values are never logged.
+var sensitiveKeys = []string{
+	"token",
+	"password",
+	"passwd",
+	"secret",
+	"authorization",
+	"cookie",
+	"jwt",
+	"credential",
+	"api_key",
+	"apikey",
+}
+
+var (
+	jwtPattern    = regexp.MustCompile(`eyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	bearerPattern = regexp.MustCompile(`(?i)(bearer\s+)\S+`)
+)
+
+// Masks the values of sensitive attributes, and the tokens found in
+// any other text, like error messages.
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	if isSensitive(a.Key) {
+		return slog.String(a.Key, redacted)
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, redactText(a.Value.String()))
+	case slog.KindAny:
+		return slog.Any(a.Key, redactValue(a.Value.Any()))
+	}
+	return a
+}
+
+// Masks the values logged with slog.Any: errors, headers, cookies, and
+// the maps and slices of them. Other structs, like requests, are logged
+// as they are, so they must not be logged.
+func redactValue(value any) any {
+	switch v := value.(type) {
+	case error:
+		return redactText(v.Error())
+	case string:
+		return redactText(v)
+	case []string:
+		redactedValues := make([]string, len(v))
+		for i, text := range v {
+			redactedValues[i] = redactText(text)
+		}
+		return redactedValues
+	case []any:
+		redactedValues := make([]any, len(v))
+		for i, item := range v {
+			redactedValues[i] = redactValue(item)
+		}
+		return redactedValues
+	case http.Header:
+		return redactMap(v)
+	case url.Values:
+		return redactMap(v)
+	case map[string][]string:
+		return redactMap(v)
+	case map[string]string:
+		return redactMap(v)
+	case map[string]any:
+		return redactMap(v)
+	case *http.Cookie:
+		return v.Name + "=" + redacted
+	case []*http.Cookie:
+		names := make([]string, len(v))
+		for i, cookie := range v {
+			names[i] = cookie.Name + "=" + redacted
+		}
+		return names
+	}
+	return value
+}
+
+// Masks the values of the sensitive keys, and redacts the other ones.
+func redactMap[V any](m map[string]V) map[string]any {
+	redactedMap := make(map[string]any, len(m))
+	for key, value := range m {
+		if isSensitive(key) {
+			redactedMap[key] = redacted
+			continue
+		}
+		redactedMap[key] = redactValue(value)
+	}
+	return redactedMap
+}
+
+// Tells if the values of the key are secret. Header names count too,
+// e.g. "Set-Cookie" or "X-Api-Key".
+func isSensitive(key string) bool {
+	key = strings.ReplaceAll(strings.ToLower(key), "-", "_")
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+func redactText(text string) string {
+	text = jwtPattern.ReplaceAllString(text, redacted)
+	return bearerPattern.ReplaceAllString(text, "${1}"+redacted)
+}
@@ -2,109 +2,346 @@ package main
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"net/netip"
 	"os"
 	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
-	"github.com/go-chi/cors"
-	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/securecookie"
 	usersClient "github.com/plagioriginal/api-gateway/clients/users"
+	"github.com/plagioriginal/api-gateway/config"
 	"github.com/plagioriginal/api-gateway/cookies"
+	"github.com/plagioriginal/api-gateway/cors"
 	"github.com/plagioriginal/api-gateway/domain"
+	csrfHandler "github.com/plagioriginal/api-gateway/handlers/v1/csrf"
 	usersHandler "github.com/plagioriginal/api-gateway/handlers/v1/users"
+	"github.com/plagioriginal/api-gateway/health"
+	"github.com/plagioriginal/api-gateway/helpers"
+	"github.com/plagioriginal/api-gateway/logging"
+	"github.com/plagioriginal/api-gateway/metrics"
 	"github.com/plagioriginal/api-gateway/middlewares"
+	"github.com/plagioriginal/api-gateway/proxy"
+	"github.com/plagioriginal/api-gateway/revocation"
 	v1 "github.com/plagioriginal/api-gateway/router/v1"
 	"github.com/plagioriginal/api-gateway/tokens"
+	"github.com/plagioriginal/api-gateway/tracing"
+	"github.com/plagioriginal/api-gateway/validation"
 	usersGrpc "github.com/plagioriginal/users-service-grpc/users"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 )
 
 func main() {
-	logger := log.New(os.Stdout, "api-gateway: ", log.Flags())
+	cfg, err := config.Load()
+	if err != nil {
+		logging.Fatal(slog.New(slog.NewJSONHandler(os.Stdout, nil)), "invalid configuration", err)
+	}
+
+	logger, logLevel, err := logging.New(cfg.Logging, os.Stdout)
+	if err != nil {
+		logging.Fatal(slog.New(slog.NewJSONHandler(os.Stdout, nil)), "invalid logging configuration", err)
+	}
+	slog.SetDefault(logger)
+
 	r := chi.NewRouter()
 
-	conn, err := grpc.Dial(os.Getenv("USERS_SERVICE_HOST"), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	// Canceled on shutdown, stops the background jobs.
+	appCtx, stopApp := context.WithCancel(context.Background())
+	defer stopApp()
+
+	shutdownTracing, err := tracing.Setup(appCtx, cfg.Tracing)
+	if err != nil {
+		logging.Fatal(logger, "couldn't set up tracing", err)
+	}
+
+	gatewayMetrics := metrics.New()
+
+	conn, err := grpc.Dial(
+		cfg.Upstreams.UsersHost,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(tracing.UnaryClientInterceptor(), gatewayMetrics.UnaryClientInterceptor()),
+	)
 	if err != nil {
-		logger.Fatalln(err)
+		logging.Fatal(logger, "couldn't dial the users service", err)
 	}
 	defer conn.Close()
 
-	r.Use(middleware.RequestID)
+	healthChecker := health.New(
+		cfg.Upstreams.Timeout,
+		health.Dependency{Name: "users", Conn: conn},
+	)
+
+	trustedProxies, err := generateTrustedProxies(cfg.Server)
+	if err != nil {
+		logging.Fatal(logger, "invalid trusted proxies", err)
+	}
+
+	r.Use(middlewares.RequestID(trustedProxies))
 	r.Use(middleware.RealIP)
-	r.Use(middleware.RequestLogger(&middleware.DefaultLogFormatter{Logger: logger, NoColor: false}))
-	r.Use(middleware.Recoverer)
-	r.Use(middleware.Timeout(60 * time.Second))
+	r.Use(tracing.Middleware)
+	r.Use(gatewayMetrics.Middleware)
+	r.Use(logging.RequestLogger(logger, "/healthz", "/readyz"))
+	r.Use(middlewares.Recoverer(logger))
+	r.Use(middlewares.Timeout(cfg.Server.RequestTimeout))
 	r.Use(middlewares.SetJsonContentType)
 
-	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   []string{"https://*", "http://*", "http://localhost:3000"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "Set-Cookie"},
-		ExposedHeaders:   []string{"Link"},
-		AllowCredentials: true,
-		MaxAge:           300,
-	}))
-
-	cookieEncoder := generateCookieHandler(logger)
-	validator := validator.New()
-	timeoutContext := time.Duration(3) * time.Second
-	userClient := usersClient.New(usersGrpc.NewUsersClient(conn), logger, timeoutContext)
-	tokenManager := tokens.NewTokenManager(os.Getenv("JWT_GENERATOR_SECRET"))
-	usersHandler := usersHandler.New(userClient, cookieEncoder, validator, logger)
-	authMiddleware := middlewares.NewAuthorizationMiddleware(tokenManager, userClient, cookieEncoder, logger)
+	corsProfile, err := generateCorsProfile(cfg)
+	if err != nil {
+		logging.Fatal(logger, "invalid cors profile", err)
+	}
+	r.Use(cors.Handler(corsProfile, logger))
+
+	r.Get("/healthz", healthChecker.Liveness)
+	r.Get("/readyz", healthChecker.Readiness)
+
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		helpers.Error(w, r, http.StatusNotFound, domain.ErrCodeNotFound, "route not found", nil)
+	})
+	r.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		helpers.Error(w, r, http.StatusMethodNotAllowed, domain.ErrCodeInvalidRequest, "method not allowed", nil)
+	})
+
+	cookieEncoder := generateCookieHandler(cfg.Cookies, logger)
+	validator, err := validation.New()
+	if err != nil {
+		logging.Fatal(logger, "couldn't create the validator", err)
+	}
+	userClient := usersClient.New(usersGrpc.NewUsersClient(conn), logger, cfg.Upstreams.Timeout)
+	keySet, err := generateKeySet(appCtx, cfg.JWT, logger)
+	if err != nil {
+		logging.Fatal(logger, "couldn't load the jwt keys", err)
+	}
+	tokenManager, err := tokens.NewTokenManager(cfg.JWT.Secret, keySet, cfg.JWT.AllowedAlgorithms, generateTokenPolicy(cfg.JWT))
+	if err != nil {
+		logging.Fatal(logger, "couldn't create the token manager", err)
+	}
+	revocationStore := revocation.NewMemoryStore(appCtx, time.Minute)
+	tokenExtractors := []middlewares.TokenExtractor{
+		middlewares.BearerTokenExtractor(),
+		middlewares.CookieTokenExtractor(cookieEncoder),
+	}
+	if cfg.Auth.QueryTokenParam != "" {
+		tokenExtractors = append(tokenExtractors, middlewares.QueryTokenExtractor(cfg.Auth.QueryTokenParam))
+	}
+	tokenRefresher := middlewares.NewTokenRefresher(userClient, cfg.Auth.RefreshTimeout, cfg.Auth.RefreshGracePeriod)
+	usersHandler := usersHandler.New(userClient, tokenRefresher, cookieEncoder, tokenManager, revocationStore, gatewayMetrics, tokenExtractors, validator, logger)
+	authMiddleware := middlewares.NewAuthorizationMiddleware(
+		tokenManager,
+		tokenRefresher,
+		cookieEncoder,
+		revocationStore,
+		gatewayMetrics,
+		logger,
+		tokenExtractors,
+	)
+
+	csrfMiddleware := middlewares.NewCSRFMiddleware(cookieEncoder, logger)
+	csrfHandler := csrfHandler.New(cookieEncoder, logger)
+
+	// Cookie sessions also need a CSRF token to change anything.
+	requireToken := func(allowedRoles []string) func(next http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return authMiddleware.RequireToken(allowedRoles)(csrfMiddleware.RequireForCookies(next))
+		}
+	}
 
 	v1.New(
 		"/v1",
 		usersHandler,
-		authMiddleware.RequireToken([]string{"admin"}),
+		csrfHandler,
+		requireToken([]string{"admin"}),
+		requireToken(nil),
+		csrfMiddleware.Require,
 	).GenerateRoutes(r)
 
+	if cfg.Proxy.RoutesFile != "" {
+		routes, err := proxy.LoadRoutes(cfg.Proxy.RoutesFile)
+		if err != nil {
+			logging.Fatal(logger, "couldn't load the proxy routes", err)
+		}
+
+		proxy.New(routes, requireToken, logger).GenerateRoutes(r)
+	}
+
 	server := &http.Server{
-		Addr:    ":" + os.Getenv("API_PORT"),
-		Handler: r,
+		Addr:         ":" + cfg.Server.Port,
+		Handler:      r,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+		ErrorLog:     slog.NewLogLogger(logger.Handler(), slog.LevelError),
 	}
 
 	// Go routine to begin the server
 	go func() {
-		logger.Printf("Listening to port %s\n", server.Addr)
+		logger.Info("listening", "address", server.Addr)
 		err := server.ListenAndServe()
 
-		if err != nil {
-			logger.Fatalln(err)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logging.Fatal(logger, "server failed", err)
 		}
 	}()
 
+	// The metrics and the log level have their own listener, to keep them private
+	var metricsServer *http.Server
+	if cfg.Metrics.Address != "" {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("/metrics", gatewayMetrics.Handler())
+		metricsMux.Handle("/log/level", logging.LevelHandler(logLevel, logger))
+		metricsServer = &http.Server{
+			Addr:     cfg.Metrics.Address,
+			Handler:  metricsMux,
+			ErrorLog: slog.NewLogLogger(logger.Handler(), slog.LevelError),
+		}
+
+		go func() {
+			logger.Info("serving metrics", "address", metricsServer.Addr)
+			err := metricsServer.ListenAndServe()
+
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logging.Fatal(logger, "metrics server failed", err)
+			}
+		}()
+	}
+
 	// Wait for an interrupt
 	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, os.Interrupt)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 	<-quit
 
+	// Report not ready while still serving, until load balancers notice
+	logger.Info("draining", "delay", cfg.Server.DrainDelay)
+	healthChecker.Drain()
+	time.Sleep(cfg.Server.DrainDelay)
+
 	// Attempt a graceful shutdown
-	ctx, cancel := context.WithTimeout(context.Background(), timeoutContext)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
 
-	logger.Println("Shutting down server...")
+	logger.Info("shutting down server")
 	if err := server.Shutdown(ctx); err != nil {
-		logger.Fatalf("Server forced to shutdown: %v\n", err)
+		logging.Fatal(logger, "server forced to shutdown", err)
+	}
+	if metricsServer != nil {
+		if err := metricsServer.Shutdown(ctx); err != nil {
+			logger.Error("metrics server forced to shutdown", "error", err)
+		}
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		logger.Error("couldn't flush the traces", "error", err)
+	}
+}
+
+func generateCookieHandler(cfg config.Cookies, l *slog.Logger) domain.CookieHandler {
+	keyPairs, err := loadCookieKeys(cfg)
+	if err != nil {
+		logging.Fatal(l, "couldn't load the cookie keys", err)
+	}
+
+	policy, err := generateCookiePolicy(cfg)
+	if err != nil {
+		logging.Fatal(l, "invalid cookie policy", err)
+	}
+
+	// Refresh cookies must still decode when they are about to expire.
+	codecs := cookies.Codecs(keyPairs)
+	for _, codec := range codecs {
+		codec.(*securecookie.SecureCookie).MaxAge(int(policy.RefreshTTL.Seconds()))
+	}
+	return cookies.New(codecs, policy)
+}
+
+// Loads the cookie keys from the key file, or from the configured pairs.
+// They must be shared by every replica and kept across deploys,
+// otherwise the sessions are lost.
+func loadCookieKeys(cfg config.Cookies) ([]cookies.KeyPair, error) {
+	if cfg.KeysFile != "" {
+		return cookies.LoadKeyPairs(cfg.KeysFile)
+	}
+	return cookies.ParseKeyPairs(strings.Join(cfg.Keys, "\n"))
+}
+
+// Attributes of the token cookies.
+func generateCookiePolicy(cfg config.Cookies) (cookies.Policy, error) {
+	sameSite, err := cookies.ParseSameSite(cfg.SameSite)
+	if err != nil {
+		return cookies.Policy{}, err
+	}
+
+	policy := cookies.Policy{
+		SameSite:   sameSite,
+		Domain:     cfg.Domain,
+		Path:       cfg.Path,
+		Secure:     cfg.Secure,
+		RefreshTTL: cfg.RefreshTTL,
+	}
+	return policy, policy.Validate()
+}
+
+// The CORS profile of the environment, read from the profiles file when
+// set. The configured origins replace the ones of the profile.
+func generateCorsProfile(cfg config.Config) (cors.Profile, error) {
+	profiles := cors.DefaultProfiles()
+	if cfg.CORS.ProfilesFile != "" {
+		loaded, err := cors.LoadProfiles(cfg.CORS.ProfilesFile)
+		if err != nil {
+			return cors.Profile{}, err
+		}
+		profiles = loaded
+	}
+
+	profile, found := profiles[cfg.Environment]
+	if !found {
+		return profile, fmt.Errorf("no cors profile for the %q environment", cfg.Environment)
+	}
+	if len(cfg.CORS.AllowedOrigins) > 0 {
+		profile.AllowedOrigins = cfg.CORS.AllowedOrigins
 	}
+
+	return profile, profile.Validate()
 }
 
-func generateCookieHandler(l *log.Logger) domain.CookieHandler {
-	hashKey := securecookie.GenerateRandomKey(32)
-	if hashKey == nil {
-		l.Fatalln("couldn't generate hashkey for cookies")
+// Loads the keys the tokens are verified with, from a local JWKS file or
+// from a JWKS URL. Returns no key set when neither is configured.
+func generateKeySet(ctx context.Context, cfg config.JWT, l *slog.Logger) (tokens.KeySet, error) {
+	if cfg.JWKSFile != "" {
+		return tokens.NewJWKSFromFile(cfg.JWKSFile, l)
 	}
-	blockKey := securecookie.GenerateRandomKey(24)
-	if blockKey == nil {
-		l.Fatalln("couldn't generate blockkey for cookies")
+	if cfg.JWKSURL != "" {
+		return tokens.NewJWKSFromURL(ctx, cfg.JWKSURL, cfg.JWKSRefreshInterval, l)
+	}
+	return nil, nil
+}
+
+// The proxies allowed to set the request ID.
+func generateTrustedProxies(cfg config.Server) ([]netip.Prefix, error) {
+	prefixes := []netip.Prefix{}
+	for _, proxy := range cfg.TrustedProxies {
+		prefix, err := helpers.ParsePrefix(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q: %w", proxy, err)
+		}
+		prefixes = append(prefixes, prefix)
 	}
+	return prefixes, nil
+}
 
-	cookieEncoder := securecookie.New(hashKey, blockKey)
-	return cookies.New(cookieEncoder)
+// Rules the claims of the tokens must follow.
+func generateTokenPolicy(cfg config.JWT) tokens.ValidationPolicy {
+	return tokens.ValidationPolicy{
+		Issuers:               cfg.Issuers,
+		Audience:              cfg.Audience,
+		RequiredClaims:        cfg.RequiredClaims,
+		MaxAge:                cfg.MaxAge,
+		Leeway:                cfg.Leeway,
+		LegacyIssuerAsSubject: cfg.LegacyIssuerAsSubject,
+	}
 }
@@ -0,0 +1,22 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// Records the latency and status code of the unary calls made through
+// the connection.
+func (m *Metrics) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+
+		m.grpcDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+		m.grpcCalls.WithLabelValues(method, status.Code(err).String()).Inc()
+		return err
+	}
+}
@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "api_gateway"
+
+// The Prometheus metrics of the gateway, on their own registry.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+
+	grpcCalls    *prometheus.CounterVec
+	grpcDuration *prometheus.HistogramVec
+
+	logins             *prometheus.CounterVec
+	refreshes          *prometheus.CounterVec
+	tokenParseFailures *prometheus.CounterVec
+	roleDenials        *prometheus.CounterVec
+}
+
+// Returns the metrics, registered along with the Go runtime and
+// process collectors.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests handled, by route pattern, method and status.",
+		}, []string{"route", "method", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time taken to handle HTTP requests, by route pattern, method and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+
+		grpcCalls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "grpc_client_calls_total",
+			Help:      "Calls made to the gRPC services, by method and status code.",
+		}, []string{"method", "code"}),
+		grpcDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "grpc_client_call_duration_seconds",
+			Help:      "Time taken by the calls to the gRPC services, by method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method"}),
+
+		logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "auth_logins_total",
+			Help:      "Logins, by result.",
+		}, []string{"result"}),
+		refreshes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "auth_token_refreshes_total",
+			Help:      "Expired sessions refreshed while authorizing a request, by result.",
+		}, []string{"result"}),
+		tokenParseFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "auth_token_parse_failures_total",
+			Help:      "Access tokens that failed to parse, by reason.",
+		}, []string{"reason"}),
+		roleDenials: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "auth_role_denials_total",
+			Help:      "Authenticated requests denied because of their role, by role.",
+		}, []string{"role"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.grpcCalls,
+		m.grpcDuration,
+		m.logins,
+		m.refreshes,
+		m.tokenParseFailures,
+		m.roleDenials,
+	)
+	return m
+}
+
+// Serves the metrics in the Prometheus format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+func (m *Metrics) LoginAttempted(result string) {
+	m.logins.WithLabelValues(result).Inc()
+}
+
+func (m *Metrics) TokenRefreshed(result string) {
+	m.refreshes.WithLabelValues(result).Inc()
+}
+
+func (m *Metrics) TokenParseFailed(reason string) {
+	m.tokenParseFailures.WithLabelValues(reason).Inc()
+}
+
+func (m *Metrics) RoleDenied(role string) {
+	m.roleDenials.WithLabelValues(role).Inc()
+}
@@ -2,93 +2,164 @@ package middlewares
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
-	"time"
+	"strings"
 
+	"github.com/golang-jwt/jwt"
+	"github.com/plagioriginal/api-gateway/auth"
 	"github.com/plagioriginal/api-gateway/domain"
 	"github.com/plagioriginal/api-gateway/helpers"
+	"github.com/plagioriginal/api-gateway/logging"
+	"github.com/plagioriginal/api-gateway/tracing"
+	"go.opentelemetry.io/otel/codes"
 )
 
 type AuthorizationMiddleware struct {
-	tm domain.TokenManager
-	uc domain.UsersClient
-	ch domain.CookieHandler
-	l  *log.Logger
+	tm         domain.TokenManager
+	ch         domain.CookieHandler
+	rs         domain.RevocationStore
+	am         domain.AuthMetrics
+	l          *slog.Logger
+	extractors []TokenExtractor
+	tr         domain.TokenRefresher
 }
 
 // Returns a new instance of the middleware
+// The extractors are tried in order, and the first token found is used.
+// Without extractors, the bearer header and then the cookie are read.
+// Expired cookie sessions are refreshed through tr, which shares the
+// new tokens with the concurrent requests of the same session.
 func NewAuthorizationMiddleware(
 	tm domain.TokenManager,
-	uc domain.UsersClient,
+	tr domain.TokenRefresher,
 	ch domain.CookieHandler,
-	l *log.Logger,
+	rs domain.RevocationStore,
+	am domain.AuthMetrics,
+	l *slog.Logger,
+	extractors []TokenExtractor,
 ) AuthorizationMiddleware {
+	if len(extractors) == 0 {
+		extractors = []TokenExtractor{
+			BearerTokenExtractor(),
+			CookieTokenExtractor(ch),
+		}
+	}
+
 	return AuthorizationMiddleware{
-		tm: tm,
-		uc: uc,
-		ch: ch,
-		l:  l,
+		tm:         tm,
+		ch:         ch,
+		rs:         rs,
+		am:         am,
+		l:          l,
+		extractors: extractors,
+		tr:         tr,
 	}
 }
 
 // Requires a valid token
+// Answers 401 when the caller couldn't be authenticated, and 403 when
+// it was authenticated but its role is not in allowedRoles.
 func (aw AuthorizationMiddleware) RequireToken(allowedRoles []string) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
-			tokenString := aw.ch.GetAccessToken(r)
+			tokenString, source := aw.extractToken(r)
 
 			if len(tokenString) == 0 {
-				w.WriteHeader(http.StatusUnauthorized)
-				helpers.JSON(w, r, "invalid token")
-				return
+				// The access cookie expires along with its token, while
+				// the session may still be refreshed.
+				if len(aw.ch.GetRefreshToken(r)) == 0 {
+					aw.unauthorized(w, r, domain.ErrCodeMissingToken, "missing token", nil)
+					return
+				}
+				source = auth.TokenSourceCookie
 			}
 
-			token, err := aw.tm.ParseToken(tokenString)
+			token, err := aw.parseToken(r.Context(), tokenString)
 			if err != nil || !aw.tm.IsTokenValid(token) {
-				newTokens, err := aw.getNewTokens(r)
-				if err != nil {
-					aw.l.Printf("error fetching new tokens: %v\n", err)
-					w.WriteHeader(http.StatusUnauthorized)
-					helpers.JSON(w, r, "invalid token")
+				code := domain.ErrCodeInvalidToken
+				if len(tokenString) == 0 || isExpired(err) {
+					code = domain.ErrCodeExpiredToken
+				}
+				if len(tokenString) > 0 {
+					reason := "invalid"
+					if isExpired(err) {
+						reason = "expired"
+					}
+					aw.am.TokenParseFailed(reason)
+				}
+
+				// Only cookie sessions with a refresh token are refreshed.
+				// Other callers manage their own tokens and don't expect
+				// cookies back.
+				if source != auth.TokenSourceCookie || len(aw.ch.GetRefreshToken(r)) == 0 {
+					aw.unauthorized(w, r, code, "invalid token", err)
 					return
 				}
 
+				newTokens, refreshErr := aw.getNewTokens(r)
+				if refreshErr != nil {
+					// An unreachable users service is not the caller's fault.
+					if httpStatus, _ := helpers.GrpcErrorStatus(refreshErr); httpStatus >= http.StatusInternalServerError {
+						aw.am.TokenRefreshed(domain.AuthResultError)
+						aw.logDenial(r, httpStatus, "refresh_unavailable", refreshErr)
+						helpers.GrpcError(w, r, refreshErr)
+						return
+					}
+
+					aw.am.TokenRefreshed(domain.AuthResultRejected)
+					aw.unauthorized(w, r, code, "token could not be refreshed", refreshErr)
+					return
+				}
+				aw.am.TokenRefreshed(domain.AuthResultSuccess)
+
 				aw.ch.GenerateCookiesFromTokens(w, newTokens.AccessToken, newTokens.RefreshToken)
 
-				token, err = aw.tm.ParseToken(newTokens.AccessToken)
+				token, err = aw.parseToken(r.Context(), newTokens.AccessToken)
 				if err != nil {
-					aw.l.Printf("error parsing token: %v\n", err)
-					w.WriteHeader(http.StatusUnauthorized)
-					helpers.JSON(w, r, "invalid token")
+					aw.unauthorized(w, r, domain.ErrCodeInvalidToken, "refreshed token is invalid", err)
 					return
 				}
 			}
 
-			userRole, err := aw.tm.GetTokenRole(token)
+			principal, err := aw.tm.GetPrincipal(token)
 			if err != nil {
-				aw.l.Printf("error fetching the role of the token: %v\n", err)
-				w.WriteHeader(http.StatusUnauthorized)
-				helpers.JSON(w, r, "invalid token")
+				aw.unauthorized(w, r, domain.ErrCodeInvalidToken, "token claims are invalid", err)
 				return
 			}
 
-			if len(allowedRoles) > 0 && !helpers.InArray(userRole, allowedRoles) {
-				w.WriteHeader(http.StatusUnauthorized)
-				helpers.JSON(w, r, "invalid token")
-				return
+			if principal.TokenID != "" {
+				revoked, err := aw.rs.IsRevoked(r.Context(), principal.TokenID)
+				if err != nil {
+					aw.logDenial(r, http.StatusServiceUnavailable, "revocation_check_failed", err)
+					helpers.Error(w, r, http.StatusServiceUnavailable, domain.ErrCodeUnavailable, "could not check the token", nil)
+					return
+				}
+				if revoked {
+					aw.unauthorized(w, r, domain.ErrCodeRevokedToken, "token was revoked", nil)
+					return
+				}
 			}
-			userId, err := aw.tm.GetTokenIssuer(token)
 
-			if err != nil {
-				aw.l.Printf("error issuer of the token: %v\n", err)
-				w.WriteHeader(http.StatusUnauthorized)
-				helpers.JSON(w, r, "invalid token")
+			if len(allowedRoles) > 0 && !helpers.InArray(principal.RoleSlug, allowedRoles) {
+				aw.am.RoleDenied(principal.RoleSlug)
+				aw.logDenial(r, http.StatusForbidden, domain.ErrCodeInsufficientRole, fmt.Errorf(
+					"user %q with role %q, allowed roles [%s]",
+					principal.UserID,
+					principal.RoleSlug,
+					strings.Join(allowedRoles, ","),
+				))
+				helpers.Error(w, r, http.StatusForbidden, domain.ErrCodeInsufficientRole, "insufficient role", nil)
 				return
 			}
 
-			ctx := context.WithValue(r.Context(), "userId", userId)
-			ctx = context.WithValue(ctx, "userRole", userRole)
+			logging.SetUserID(r.Context(), principal.UserID)
+
+			ctx := auth.WithPrincipal(r.Context(), principal)
+			ctx = auth.WithAccessToken(ctx, token.Raw)
+			ctx = auth.WithTokenSource(ctx, source)
 
 			next.ServeHTTP(w, r.WithContext(ctx))
 		}
@@ -97,21 +168,73 @@ func (aw AuthorizationMiddleware) RequireToken(allowedRoles []string) func(next
 	}
 }
 
+// Reads the access token with the first extractor that finds one.
+func (aw AuthorizationMiddleware) extractToken(r *http.Request) (string, auth.TokenSource) {
+	for _, extractor := range aw.extractors {
+		if token := extractor.Extract(r); token != "" {
+			return token, extractor.Source
+		}
+	}
+	return "", ""
+}
+
+// Parses the token within a span, to tell the time spent verifying it.
+func (aw AuthorizationMiddleware) parseToken(ctx context.Context, tokenString string) (*jwt.Token, error) {
+	_, span := tracing.Tracer().Start(ctx, "token.parse")
+	defer span.End()
+
+	token, err := aw.tm.ParseToken(tokenString)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return token, err
+}
+
 // Gets new tokens from the UsersClient
 func (aw AuthorizationMiddleware) getNewTokens(r *http.Request) (domain.TokenResponse, error) {
-	refreshToken := aw.ch.GetRefreshToken(r)
-	aw.l.Println(refreshToken)
+	ctx, span := tracing.Tracer().Start(r.Context(), "token.refresh")
+	defer span.End()
 
-	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(2)*time.Second)
-	defer cancel()
+	refreshToken := aw.ch.GetRefreshToken(r.WithContext(ctx))
 
-	res, err := aw.uc.RefreshJWT(ctx, refreshToken)
+	tokens, err := aw.tr.Refresh(ctx, refreshToken)
 	if err != nil {
-		return domain.TokenResponse{}, err
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return tokens, err
+}
+
+// Answers a request that failed authentication, telling the client
+// why through the WWW-Authenticate header (RFC 6750).
+func (aw AuthorizationMiddleware) unauthorized(w http.ResponseWriter, r *http.Request, code string, message string, cause error) {
+	aw.logDenial(r, http.StatusUnauthorized, code, cause)
+
+	challenge := `Bearer realm="api-gateway"`
+	if code != domain.ErrCodeMissingToken {
+		challenge += fmt.Sprintf(`, error="%s", error_description="%s"`, code, message)
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+
+	helpers.Error(w, r, http.StatusUnauthorized, code, message, nil)
+}
+
+// Logs why a request was denied.
+func (aw AuthorizationMiddleware) logDenial(r *http.Request, status int, reason string, cause error) {
+	attrs := []slog.Attr{
+		slog.Int("status", status),
+		slog.String("reason", reason),
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.Path),
+	}
+	if cause != nil {
+		attrs = append(attrs, slog.Any("error", cause))
 	}
 
-	return domain.TokenResponse{
-		AccessToken:  res.AccessToken,
-		RefreshToken: res.RefreshToken,
-	}, nil
+	aw.l.LogAttrs(r.Context(), slog.LevelWarn, "auth denied", attrs...)
+}
+
+// Checks if a token failed parsing only because it expired.
+func isExpired(err error) bool {
+	var validationErr *jwt.ValidationError
+	return errors.As(err, &validationErr) && validationErr.Errors&jwt.ValidationErrorExpired != 0
 }
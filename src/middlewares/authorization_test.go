@@ -0,0 +1,225 @@
+package middlewares
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/plagioriginal/api-gateway/auth"
+	"github.com/plagioriginal/api-gateway/domain"
+)
+
+// Token manager accepting the tokens of its principals only.
+type fakeTokenManager struct {
+	principals map[string]auth.Principal
+}
+
+func (tm fakeTokenManager) ParseToken(tokenString string) (*jwt.Token, error) {
+	if _, found := tm.principals[tokenString]; !found {
+		return nil, jwt.NewValidationError("signature is invalid", jwt.ValidationErrorSignatureInvalid)
+	}
+	return &jwt.Token{Raw: tokenString, Valid: true}, nil
+}
+
+func (tm fakeTokenManager) IsTokenValid(token *jwt.Token) bool {
+	return token != nil && token.Valid
+}
+
+func (tm fakeTokenManager) GetPrincipal(token *jwt.Token) (auth.Principal, error) {
+	return tm.principals[token.Raw], nil
+}
+
+// Cookie handler reading the tokens from plain cookies.
+type fakeSessionCookies struct {
+	domain.CookieHandler
+}
+
+func (fakeSessionCookies) GetAccessToken(r *http.Request) string {
+	cookie, err := r.Cookie("access-token")
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+func (fakeSessionCookies) GetRefreshToken(r *http.Request) string {
+	cookie, err := r.Cookie("refresh-token")
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+func (fakeSessionCookies) GenerateCookiesFromTokens(w http.ResponseWriter, accessToken string, refreshToken string) {
+	http.SetCookie(w, &http.Cookie{Name: "access-token", Value: accessToken})
+	http.SetCookie(w, &http.Cookie{Name: "refresh-token", Value: refreshToken})
+}
+
+// Revocation store with a fixed list of revoked token IDs.
+type fakeRevocationStore map[string]bool
+
+func (rs fakeRevocationStore) Revoke(ctx context.Context, tokenId string, expiresAt time.Time) error {
+	rs[tokenId] = true
+	return nil
+}
+
+func (rs fakeRevocationStore) IsRevoked(ctx context.Context, tokenId string) (bool, error) {
+	return rs[tokenId], nil
+}
+
+type nopAuthMetrics struct{}
+
+func (nopAuthMetrics) LoginAttempted(result string)   {}
+func (nopAuthMetrics) TokenRefreshed(result string)   {}
+func (nopAuthMetrics) TokenParseFailed(reason string) {}
+func (nopAuthMetrics) RoleDenied(role string)         {}
+
+func TestRequireToken(t *testing.T) {
+	tm := fakeTokenManager{principals: map[string]auth.Principal{
+		"user-token":           {UserID: "1", RoleSlug: "user", TokenID: "user-jti"},
+		"admin-token":          {UserID: "2", RoleSlug: "admin", TokenID: "admin-jti"},
+		"refresh-token-access": {UserID: "1", RoleSlug: "user", TokenID: "refreshed-jti"},
+	}}
+
+	tests := []struct {
+		name          string
+		bearer        string
+		accessCookie  string
+		refreshCookie string
+		allowedRoles  []string
+		revoked       fakeRevocationStore
+		wantStatus    int
+		wantCode      string
+		wantRefreshes int32
+	}{
+		{name: "bearer token", bearer: "user-token", wantStatus: http.StatusOK},
+		{name: "cookie token", accessCookie: "user-token", wantStatus: http.StatusOK},
+		{name: "missing token", wantStatus: http.StatusUnauthorized, wantCode: domain.ErrCodeMissingToken},
+		{name: "invalid bearer token", bearer: "stale", wantStatus: http.StatusUnauthorized, wantCode: domain.ErrCodeInvalidToken},
+		{
+			name:          "invalid bearer token with refresh cookie",
+			bearer:        "stale",
+			refreshCookie: "refresh-token",
+			wantStatus:    http.StatusUnauthorized,
+			wantCode:      domain.ErrCodeInvalidToken,
+		},
+		{
+			name:         "invalid cookie token without refresh cookie",
+			accessCookie: "stale",
+			wantStatus:   http.StatusUnauthorized,
+			wantCode:     domain.ErrCodeInvalidToken,
+		},
+		{
+			name:          "invalid cookie token with refresh cookie",
+			accessCookie:  "stale",
+			refreshCookie: "refresh-token",
+			wantStatus:    http.StatusOK,
+			wantRefreshes: 1,
+		},
+		{
+			name:          "expired access cookie with refresh cookie",
+			refreshCookie: "refresh-token",
+			wantStatus:    http.StatusOK,
+			wantRefreshes: 1,
+		},
+		{
+			name:       "revoked token",
+			bearer:     "user-token",
+			revoked:    fakeRevocationStore{"user-jti": true},
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   domain.ErrCodeRevokedToken,
+		},
+		{
+			name:       "other token revoked",
+			bearer:     "user-token",
+			revoked:    fakeRevocationStore{"admin-jti": true},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:          "revoked refreshed token",
+			accessCookie:  "stale",
+			refreshCookie: "refresh-token",
+			revoked:       fakeRevocationStore{"refreshed-jti": true},
+			wantStatus:    http.StatusUnauthorized,
+			wantCode:      domain.ErrCodeRevokedToken,
+			wantRefreshes: 1,
+		},
+		{name: "allowed role", bearer: "admin-token", allowedRoles: []string{"admin"}, wantStatus: http.StatusOK},
+		{
+			name:         "other role",
+			bearer:       "user-token",
+			allowedRoles: []string{"admin"},
+			wantStatus:   http.StatusForbidden,
+			wantCode:     domain.ErrCodeInsufficientRole,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newFakeRefreshClient()
+			close(client.release)
+
+			revoked := tt.revoked
+			if revoked == nil {
+				revoked = fakeRevocationStore{}
+			}
+
+			aw := NewAuthorizationMiddleware(
+				tm,
+				NewTokenRefresher(client, time.Second, time.Minute),
+				fakeSessionCookies{},
+				revoked,
+				nopAuthMetrics{},
+				slog.New(slog.NewTextHandler(io.Discard, nil)),
+				nil,
+			)
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.bearer != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.bearer)
+			}
+			if tt.accessCookie != "" {
+				req.AddCookie(&http.Cookie{Name: "access-token", Value: tt.accessCookie})
+			}
+			if tt.refreshCookie != "" {
+				req.AddCookie(&http.Cookie{Name: "refresh-token", Value: tt.refreshCookie})
+			}
+
+			rec := httptest.NewRecorder()
+			aw.RequireToken(tt.allowedRoles)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if _, found := auth.PrincipalFromContext(r.Context()); !found {
+					t.Error("request reached the handler without a principal")
+				}
+				w.WriteHeader(http.StatusOK)
+			})).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d, body %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if calls := atomic.LoadInt32(&client.calls); calls != tt.wantRefreshes {
+				t.Errorf("users service called %d times, want %d", calls, tt.wantRefreshes)
+			}
+			if tt.wantCode == "" {
+				return
+			}
+
+			var body domain.ErrorResponse
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Code != tt.wantCode {
+				t.Errorf("code = %q, want %q", body.Code, tt.wantCode)
+			}
+			if challenge := rec.Header().Get("WWW-Authenticate"); (challenge != "") != (tt.wantStatus == http.StatusUnauthorized) {
+				t.Errorf("WWW-Authenticate = %q with status %d", challenge, rec.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+
+	"github.com/plagioriginal/api-gateway/auth"
+	"github.com/plagioriginal/api-gateway/domain"
+	"github.com/plagioriginal/api-gateway/helpers"
+)
+
+// Double submit protection: state changing requests must repeat on the
+// X-CSRF-Token header the token held by the signed csrf cookie.
+// Other sites can make the browser send the cookie, but can't read it.
+type CSRFMiddleware struct {
+	ch domain.CookieHandler
+	l  *slog.Logger
+}
+
+// Returns a new instance of the middleware
+func NewCSRFMiddleware(ch domain.CookieHandler, l *slog.Logger) CSRFMiddleware {
+	return CSRFMiddleware{
+		ch: ch,
+		l:  l,
+	}
+}
+
+// Requires the token on the state changing requests of browsers.
+// Meant for the routes that use the cookies without authenticating,
+// like login, refresh and logout.
+// Callers sending the Authorization header, and the ones sending neither
+// cookies nor an Origin header, like CLIs, aren't browsers led there by
+// another site, since browsers add the Origin on cross site requests.
+func (cm CSRFMiddleware) Require(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isBrowserRequest(r) && !cm.isValid(r) {
+			cm.deny(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Requires the token on state changing requests authenticated by cookie.
+// Must run after RequireToken. Bearer callers aren't exposed to CSRF,
+// since browsers never add the header on their own.
+func (cm CSRFMiddleware) RequireForCookies(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if source, _ := auth.TokenSourceFromContext(r.Context()); source == auth.TokenSourceCookie && !cm.isValid(r) {
+			cm.deny(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func isBrowserRequest(r *http.Request) bool {
+	if r.Header.Get("Authorization") != "" {
+		return false
+	}
+	return r.Header.Get("Origin") != "" || len(r.Cookies()) > 0
+}
+
+func (cm CSRFMiddleware) isValid(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+
+	submitted := r.Header.Get(domain.CSRFTokenHeader)
+	expected := cm.ch.GetCSRFToken(r)
+	if submitted == "" || expected == "" {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(submitted), []byte(expected)) == 1
+}
+
+func (cm CSRFMiddleware) deny(w http.ResponseWriter, r *http.Request) {
+	cm.l.WarnContext(r.Context(), "csrf denied", "method", r.Method, "path", r.URL.Path)
+
+	helpers.Error(w, r, http.StatusForbidden, domain.ErrCodeInvalidCSRFToken, "missing or invalid csrf token", nil)
+}
@@ -0,0 +1,97 @@
+package middlewares
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/plagioriginal/api-gateway/auth"
+	"github.com/plagioriginal/api-gateway/domain"
+)
+
+// Cookie handler reading the CSRF token from a plain cookie.
+type fakeCSRFCookies struct {
+	domain.CookieHandler
+}
+
+func (fakeCSRFCookies) GetCSRFToken(r *http.Request) string {
+	cookie, err := r.Cookie("csrf-token")
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+func TestCSRFMiddleware(t *testing.T) {
+	cm := NewCSRFMiddleware(fakeCSRFCookies{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	tests := []struct {
+		name       string
+		method     string
+		cookie     string
+		header     string
+		origin     string
+		bearer     bool
+		source     auth.TokenSource
+		wantStatus int
+	}{
+		{name: "safe method", method: http.MethodGet, origin: "https://app.example.com", source: auth.TokenSourceCookie, wantStatus: http.StatusOK},
+		{name: "matching token", method: http.MethodPost, cookie: "abc", header: "abc", source: auth.TokenSourceCookie, wantStatus: http.StatusOK},
+		{name: "other token", method: http.MethodPost, cookie: "abc", header: "abd", source: auth.TokenSourceCookie, wantStatus: http.StatusForbidden},
+		{name: "missing header", method: http.MethodDelete, cookie: "abc", source: auth.TokenSourceCookie, wantStatus: http.StatusForbidden},
+		{name: "missing cookie", method: http.MethodPatch, header: "abc", origin: "https://evil.com", source: auth.TokenSourceCookie, wantStatus: http.StatusForbidden},
+		{name: "browser without cookies", method: http.MethodPost, origin: "https://evil.com", wantStatus: http.StatusForbidden},
+		{name: "bearer caller", method: http.MethodPost, bearer: true, source: auth.TokenSourceBearer, wantStatus: http.StatusOK},
+		{name: "bearer caller with cookies", method: http.MethodPost, cookie: "abc", bearer: true, source: auth.TokenSourceBearer, wantStatus: http.StatusOK},
+		{name: "caller without cookies nor origin", method: http.MethodPost, wantStatus: http.StatusOK},
+	}
+
+	middlewares := []struct {
+		name    string
+		handler func(next http.Handler) http.Handler
+		// RequireForCookies only checks the requests authenticated by cookie.
+		onlyCookieSessions bool
+	}{
+		{"Require", cm.Require, false},
+		{"RequireForCookies", cm.RequireForCookies, true},
+	}
+
+	for _, mw := range middlewares {
+		for _, tt := range tests {
+			t.Run(mw.name+"/"+tt.name, func(t *testing.T) {
+				req := httptest.NewRequest(tt.method, "/", nil)
+				if tt.cookie != "" {
+					req.AddCookie(&http.Cookie{Name: "csrf-token", Value: tt.cookie})
+				}
+				if tt.header != "" {
+					req.Header.Set(domain.CSRFTokenHeader, tt.header)
+				}
+				if tt.origin != "" {
+					req.Header.Set("Origin", tt.origin)
+				}
+				if tt.bearer {
+					req.Header.Set("Authorization", "Bearer token")
+				}
+				if tt.source != "" {
+					req = req.WithContext(auth.WithTokenSource(req.Context(), tt.source))
+				}
+
+				wantStatus := tt.wantStatus
+				if mw.onlyCookieSessions && tt.source != auth.TokenSourceCookie {
+					wantStatus = http.StatusOK
+				}
+
+				rec := httptest.NewRecorder()
+				mw.handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusOK)
+				})).ServeHTTP(rec, req)
+
+				if rec.Code != wantStatus {
+					t.Errorf("status = %d, want %d", rec.Code, wantStatus)
+				}
+			})
+		}
+	}
+}
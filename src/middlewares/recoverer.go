@@ -0,0 +1,34 @@
+package middlewares
+
+import (
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/plagioriginal/api-gateway/domain"
+	"github.com/plagioriginal/api-gateway/helpers"
+)
+
+// Recovers from the panics of the handlers, answering with the standard
+// error body so the caller still gets the request ID.
+func Recoverer(l *slog.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				recovered := recover()
+				if recovered == nil {
+					return
+				}
+				if recovered == http.ErrAbortHandler {
+					// Meant to abort the response, not to be answered.
+					panic(recovered)
+				}
+
+				l.ErrorContext(r.Context(), "panic", "panic", recovered, "stack", string(debug.Stack()))
+				helpers.Error(w, r, http.StatusInternalServerError, domain.ErrCodeInternal, http.StatusText(http.StatusInternalServerError), nil)
+			}()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/netip"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Longest request ID accepted from a proxy.
+const maxRequestIDLength = 128
+
+// Gives each request an ID, echoed on the X-Request-ID response header.
+// The ID sent by the caller is kept only when it comes from one of the
+// trusted proxies, so clients can't pick the IDs found in the logs.
+// Must run before middleware.RealIP, which rewrites the remote address.
+func RequestID(trustedProxies []netip.Prefix) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(middleware.RequestIDHeader, middleware.GetReqID(r.Context()))
+			next.ServeHTTP(w, r)
+		})
+		generate := middleware.RequestID(echo)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID := r.Header.Get(middleware.RequestIDHeader)
+			if requestID != "" && (!isTrustedProxy(r.RemoteAddr, trustedProxies) || !isValidRequestID(requestID)) {
+				// Generated instead.
+				r.Header.Del(middleware.RequestIDHeader)
+			}
+			generate.ServeHTTP(w, r)
+		})
+	}
+}
+
+func isTrustedProxy(remoteAddr string, trustedProxies []netip.Prefix) bool {
+	addrPort, err := netip.ParseAddrPort(remoteAddr)
+	if err != nil {
+		return false
+	}
+
+	addr := addrPort.Addr().Unmap()
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Short and made of printable ASCII, so it is safe to log and to send
+// back on headers and gRPC metadata.
+func isValidRequestID(requestID string) bool {
+	if len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(requestID); i++ {
+		if requestID[i] <= ' ' || requestID[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,66 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+func TestRequestID(t *testing.T) {
+	trustedProxies := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("2001:db8::/32"),
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		requestID  string
+		wantKept   bool
+	}{
+		{name: "trusted proxy", remoteAddr: "10.1.2.3:4321", requestID: "proxy-id-1", wantKept: true},
+		{name: "trusted ipv6 proxy", remoteAddr: "[2001:db8::1]:4321", requestID: "proxy-id-1", wantKept: true},
+		{name: "ipv4-mapped trusted proxy", remoteAddr: "[::ffff:10.1.2.3]:4321", requestID: "proxy-id-1", wantKept: true},
+		{name: "untrusted client", remoteAddr: "203.0.113.7:4321", requestID: "client-id-1"},
+		{name: "ipv4-mapped untrusted client", remoteAddr: "[::ffff:203.0.113.7]:4321", requestID: "client-id-1"},
+		{name: "remote address without port", remoteAddr: "10.1.2.3", requestID: "proxy-id-1"},
+		{name: "longest id", remoteAddr: "10.1.2.3:4321", requestID: strings.Repeat("a", maxRequestIDLength), wantKept: true},
+		{name: "too long id", remoteAddr: "10.1.2.3:4321", requestID: strings.Repeat("a", maxRequestIDLength+1)},
+		{name: "id with spaces", remoteAddr: "10.1.2.3:4321", requestID: "proxy id"},
+		{name: "id with control characters", remoteAddr: "10.1.2.3:4321", requestID: "proxy-id\x1b[31m"},
+		{name: "id with non ascii characters", remoteAddr: "10.1.2.3:4321", requestID: "proxy-id-é"},
+		{name: "no id", remoteAddr: "10.1.2.3:4321"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var handlerID string
+			handler := RequestID(trustedProxies)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				handlerID = middleware.GetReqID(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.requestID != "" {
+				req.Header.Set(middleware.RequestIDHeader, tt.requestID)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if handlerID == "" {
+				t.Fatal("request reached the handler without an ID")
+			}
+			if echoed := rec.Header().Get(middleware.RequestIDHeader); echoed != handlerID {
+				t.Errorf("echoed ID %q, want %q", echoed, handlerID)
+			}
+			if kept := handlerID == tt.requestID; kept != tt.wantKept {
+				t.Errorf("ID %q with %q sent, kept = %v, want %v", handlerID, tt.requestID, kept, tt.wantKept)
+			}
+		})
+	}
+}
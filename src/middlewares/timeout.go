@@ -0,0 +1,31 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/plagioriginal/api-gateway/domain"
+	"github.com/plagioriginal/api-gateway/helpers"
+)
+
+// Cancels the context of the requests that take longer than timeout.
+// Handlers that stop on the cancelled context without answering get the
+// standard error body with a 504, like the rest of the gateway errors.
+func Timeout(timeout time.Duration) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(ctx))
+
+			if ww.Status() == 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				helpers.Error(w, r, http.StatusGatewayTimeout, domain.ErrCodeTimeout, "request timed out", nil)
+			}
+		})
+	}
+}
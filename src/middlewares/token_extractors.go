@@ -0,0 +1,52 @@
+package middlewares
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/plagioriginal/api-gateway/auth"
+	"github.com/plagioriginal/api-gateway/domain"
+)
+
+// Reads the access token from one place of the request.
+// Extract returns an empty string when the token is not there.
+type TokenExtractor struct {
+	Source  auth.TokenSource
+	Extract func(r *http.Request) string
+}
+
+// Reads the token from the "Authorization: Bearer <jwt>" header.
+func BearerTokenExtractor() TokenExtractor {
+	return TokenExtractor{
+		Source: auth.TokenSourceBearer,
+		Extract: func(r *http.Request) string {
+			parts := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
+			if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+				return ""
+			}
+			return strings.TrimSpace(parts[1])
+		},
+	}
+}
+
+// Reads the token from the encrypted access token cookie.
+func CookieTokenExtractor(ch domain.CookieHandler) TokenExtractor {
+	return TokenExtractor{
+		Source:  auth.TokenSourceCookie,
+		Extract: ch.GetAccessToken,
+	}
+}
+
+// Reads the token from a query parameter. Only used on WebSocket
+// upgrades, since browsers can't set headers on those.
+func QueryTokenExtractor(param string) TokenExtractor {
+	return TokenExtractor{
+		Source: auth.TokenSourceQuery,
+		Extract: func(r *http.Request) string {
+			if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+				return ""
+			}
+			return r.URL.Query().Get(param)
+		},
+	}
+}
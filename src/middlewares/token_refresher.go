@@ -0,0 +1,114 @@
+package middlewares
+
+import (
+	"context"
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/plagioriginal/api-gateway/domain"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Coalesces the concurrent refreshes of the same refresh token into a
+// single call to the users service. With rotating refresh tokens only the
+// first call would succeed, so every waiting request gets its result, and
+// so do the requests arriving within the grace period after it.
+type tokenRefresher struct {
+	uc          domain.UsersClient
+	timeout     time.Duration
+	gracePeriod time.Duration
+
+	mu    sync.Mutex
+	calls map[[sha256.Size]byte]*refreshCall
+}
+
+// A refresh in flight, or done and shared until expiresAt.
+type refreshCall struct {
+	done      chan struct{}
+	tokens    domain.TokenResponse
+	err       error
+	expiresAt time.Time
+}
+
+// Returns a refresher to be shared by everything that refreshes tokens,
+// so their exchanges are coalesced together.
+func NewTokenRefresher(uc domain.UsersClient, timeout time.Duration, gracePeriod time.Duration) domain.TokenRefresher {
+	return &tokenRefresher{
+		uc:          uc,
+		timeout:     timeout,
+		gracePeriod: gracePeriod,
+		calls:       map[[sha256.Size]byte]*refreshCall{},
+	}
+}
+
+// Exchanges the refresh token for a new token pair, joining the
+// exchange already in flight for the same token, if there is one.
+func (tr *tokenRefresher) Refresh(ctx context.Context, refreshToken string) (domain.TokenResponse, error) {
+	if refreshToken == "" {
+		return domain.TokenResponse{}, domain.ErrInvalidToken
+	}
+
+	// Keyed by hash, so the tokens are not kept around.
+	key := sha256.Sum256([]byte(refreshToken))
+
+	tr.mu.Lock()
+	tr.removeExpired(time.Now())
+	call, found := tr.calls[key]
+	if !found {
+		call = &refreshCall{done: make(chan struct{})}
+		tr.calls[key] = call
+
+		// Not bound to the request that started it, since others wait on
+		// it, but still part of its trace and sent with its request ID.
+		go tr.exchange(detach(ctx), key, call, refreshToken)
+	}
+	tr.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.tokens, call.err
+	case <-ctx.Done():
+		return domain.TokenResponse{}, ctx.Err()
+	}
+}
+
+func (tr *tokenRefresher) exchange(ctx context.Context, key [sha256.Size]byte, call *refreshCall, refreshToken string) {
+	ctx, cancel := context.WithTimeout(ctx, tr.timeout)
+	defer cancel()
+
+	res, err := tr.uc.RefreshJWT(ctx, refreshToken)
+	if err == nil {
+		call.tokens = *res
+	}
+	call.err = err
+
+	tr.mu.Lock()
+	if err != nil {
+		// Failures are not shared past the requests already waiting.
+		delete(tr.calls, key)
+	} else {
+		call.expiresAt = time.Now().Add(tr.gracePeriod)
+	}
+	tr.mu.Unlock()
+
+	close(call.done)
+}
+
+// A context that isn't canceled with ctx, keeping its span and
+// request ID.
+func detach(ctx context.Context) context.Context {
+	detached := trace.ContextWithSpanContext(context.Background(), trace.SpanContextFromContext(ctx))
+	return context.WithValue(detached, middleware.RequestIDKey, middleware.GetReqID(ctx))
+}
+
+// Drops the finished calls whose grace period is over.
+// Must be called with the lock held.
+func (tr *tokenRefresher) removeExpired(now time.Time) {
+	for key, call := range tr.calls {
+		if !call.expiresAt.IsZero() && now.After(call.expiresAt) {
+			delete(tr.calls, key)
+		}
+	}
+}
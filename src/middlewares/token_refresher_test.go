@@ -0,0 +1,182 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/plagioriginal/api-gateway/domain"
+)
+
+// Users client answering the refreshes once release is closed.
+type fakeRefreshClient struct {
+	domain.UsersClient
+	calls      int32
+	release    chan struct{}
+	err        error
+	requestIDs chan string
+}
+
+func newFakeRefreshClient() *fakeRefreshClient {
+	return &fakeRefreshClient{
+		release:    make(chan struct{}),
+		requestIDs: make(chan string, 10),
+	}
+}
+
+func (c *fakeRefreshClient) RefreshJWT(ctx context.Context, refreshToken string) (*domain.TokenResponse, error) {
+	call := atomic.AddInt32(&c.calls, 1)
+	c.requestIDs <- middleware.GetReqID(ctx)
+
+	select {
+	case <-c.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &domain.TokenResponse{
+		AccessToken:  refreshToken + "-access",
+		RefreshToken: refreshToken + "-refresh-" + strconv.Itoa(int(call)),
+	}, nil
+}
+
+func TestTokenRefresherCoalescesConcurrentRefreshes(t *testing.T) {
+	client := newFakeRefreshClient()
+	refresher := NewTokenRefresher(client, time.Second, time.Minute)
+
+	const requests = 20
+	results := make([]domain.TokenResponse, requests)
+	errs := make([]error, requests)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = refresher.Refresh(context.Background(), "token")
+		}(i)
+	}
+
+	// Let every request join the call before it finishes.
+	<-client.requestIDs
+	time.Sleep(50 * time.Millisecond)
+	close(client.release)
+	wg.Wait()
+
+	if calls := atomic.LoadInt32(&client.calls); calls != 1 {
+		t.Fatalf("users service called %d times, want 1", calls)
+	}
+	for i := 0; i < requests; i++ {
+		if errs[i] != nil {
+			t.Fatalf("request %d: refresh() error = %v", i, errs[i])
+		}
+		if results[i] != results[0] {
+			t.Errorf("request %d got %+v, want the shared %+v", i, results[i], results[0])
+		}
+	}
+}
+
+func TestTokenRefresherSharesResultDuringGracePeriod(t *testing.T) {
+	tests := []struct {
+		name        string
+		gracePeriod time.Duration
+		wait        time.Duration
+		wantCalls   int32
+	}{
+		{"within the grace period", time.Minute, 0, 1},
+		{"after the grace period", 10 * time.Millisecond, 30 * time.Millisecond, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newFakeRefreshClient()
+			close(client.release)
+			refresher := NewTokenRefresher(client, time.Second, tt.gracePeriod)
+
+			first, err := refresher.Refresh(context.Background(), "token")
+			if err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(tt.wait)
+			second, err := refresher.Refresh(context.Background(), "token")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if calls := atomic.LoadInt32(&client.calls); calls != tt.wantCalls {
+				t.Errorf("users service called %d times, want %d", calls, tt.wantCalls)
+			}
+			if shared := first == second; shared != (tt.wantCalls == 1) {
+				t.Errorf("second refresh got %+v after %+v, want shared %v", second, first, tt.wantCalls == 1)
+			}
+		})
+	}
+}
+
+func TestTokenRefresherDoesNotShareFailures(t *testing.T) {
+	client := newFakeRefreshClient()
+	client.err = errors.New("users service down")
+	close(client.release)
+	refresher := NewTokenRefresher(client, time.Second, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		if _, err := refresher.Refresh(context.Background(), "token"); err == nil {
+			t.Fatalf("refresh %d: want an error", i)
+		}
+	}
+
+	if calls := atomic.LoadInt32(&client.calls); calls != 2 {
+		t.Errorf("users service called %d times, want a new call after the failure", calls)
+	}
+}
+
+func TestTokenRefresherOutlivesCanceledRequest(t *testing.T) {
+	client := newFakeRefreshClient()
+	refresher := NewTokenRefresher(client, time.Second, time.Minute)
+
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), middleware.RequestIDKey, "request-1"))
+	done := make(chan error)
+	go func() {
+		_, err := refresher.Refresh(ctx, "token")
+		done <- err
+	}()
+
+	if requestID := <-client.requestIDs; requestID != "request-1" {
+		t.Errorf("users service got request ID %q, want %q", requestID, "request-1")
+	}
+
+	// The first request gives up, the one arriving next still gets the
+	// tokens of the call in flight.
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("canceled refresh() error = %v, want context.Canceled", err)
+	}
+
+	close(client.release)
+	if _, err := refresher.Refresh(context.Background(), "token"); err != nil {
+		t.Fatalf("refresh() error = %v", err)
+	}
+	if calls := atomic.LoadInt32(&client.calls); calls != 1 {
+		t.Errorf("users service called %d times, want 1", calls)
+	}
+}
+
+func TestTokenRefresherRejectsEmptyToken(t *testing.T) {
+	client := newFakeRefreshClient()
+	refresher := NewTokenRefresher(client, time.Second, time.Minute)
+
+	if _, err := refresher.Refresh(context.Background(), ""); !errors.Is(err, domain.ErrInvalidToken) {
+		t.Errorf("refresh() error = %v, want domain.ErrInvalidToken", err)
+	}
+	if calls := atomic.LoadInt32(&client.calls); calls != 0 {
+		t.Errorf("users service called %d times, want 0", calls)
+	}
+}
@@ -0,0 +1,124 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/plagioriginal/api-gateway/auth"
+	"github.com/plagioriginal/api-gateway/cookies"
+	"github.com/plagioriginal/api-gateway/domain"
+	"github.com/plagioriginal/api-gateway/helpers"
+)
+
+// Headers carrying the authenticated identity to the downstream services.
+const (
+	userIdHeader   = "X-User-Id"
+	userRoleHeader = "X-User-Role"
+)
+
+// Mounts the downstream services of the route table on the gateway.
+type Router struct {
+	routes       []Route
+	requireToken func(allowedRoles []string) func(next http.Handler) http.Handler
+	l            *slog.Logger
+}
+
+func New(
+	routes []Route,
+	requireToken func(allowedRoles []string) func(next http.Handler) http.Handler,
+	l *slog.Logger,
+) Router {
+	return Router{
+		routes:       routes,
+		requireToken: requireToken,
+		l:            l,
+	}
+}
+
+func (router Router) GenerateRoutes(mux *chi.Mux) {
+	for _, route := range router.routes {
+		route := route
+
+		mux.Route(route.Prefix, func(r chi.Router) {
+			if route.RequireAuth {
+				r.Use(router.requireToken(route.AllowedRoles))
+			}
+			r.Handle("/*", router.handler(route))
+		})
+	}
+}
+
+// Builds the reverse proxy for a single route.
+func (router Router) handler(route Route) http.Handler {
+	proxy := &httputil.ReverseProxy{
+		Director: func(req *http.Request) {
+			req.URL.Scheme = route.upstreamURL.Scheme
+			req.URL.Host = route.upstreamURL.Host
+			req.URL.Path = route.upstreamPath(req.URL.Path)
+			req.URL.RawPath = ""
+			req.Host = route.upstreamURL.Host
+
+			// Never trust the identity headers sent by the client, and
+			// keep its credentials to the gateway.
+			req.Header.Del(userIdHeader)
+			req.Header.Del(userRoleHeader)
+			req.Header.Del("Authorization")
+			req.Header.Del(domain.CSRFTokenHeader)
+			removeSessionCookies(req)
+			if principal, ok := auth.PrincipalFromContext(req.Context()); ok {
+				req.Header.Set(userIdHeader, principal.UserID)
+				req.Header.Set(userRoleHeader, principal.RoleSlug)
+			}
+
+			req.Header.Set(middleware.RequestIDHeader, middleware.GetReqID(req.Context()))
+
+			for name, value := range route.Headers {
+				req.Header.Set(name, value)
+			}
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			router.l.ErrorContext(r.Context(), "error proxying", "path", r.URL.Path, "upstream", route.Upstream, "error", err)
+
+			// Removed for the upstream, the error body is ours.
+			w.Header().Set("Content-Type", "application/json")
+
+			if errors.Is(err, context.DeadlineExceeded) {
+				helpers.Error(w, r, http.StatusGatewayTimeout, domain.ErrCodeTimeout, "upstream timed out", nil)
+				return
+			}
+			helpers.Error(w, r, http.StatusBadGateway, domain.ErrCodeBadGateway, "upstream unavailable", nil)
+		},
+		ErrorLog: slog.NewLogLogger(router.l.Handler(), slog.LevelError),
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), route.Timeout.Duration)
+		defer cancel()
+
+		// The upstream sets its own content type.
+		w.Header().Del("Content-Type")
+		proxy.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Drops the cookies of the gateway session from the request, keeping
+// the other ones.
+func removeSessionCookies(req *http.Request) {
+	sessionCookies := cookies.SessionCookieNames()
+	kept := []*http.Cookie{}
+	for _, cookie := range req.Cookies() {
+		if !helpers.InArray(cookie.Name, sessionCookies) {
+			kept = append(kept, cookie)
+		}
+	}
+
+	req.Header.Del("Cookie")
+	for _, cookie := range kept {
+		req.AddCookie(cookie)
+	}
+}
@@ -0,0 +1,207 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/plagioriginal/api-gateway/auth"
+	"github.com/plagioriginal/api-gateway/domain"
+)
+
+// What the upstream received.
+type forwardedRequest struct {
+	Path    string
+	Host    string
+	Header  http.Header
+	Cookies []string
+}
+
+func newRecordingUpstream(t *testing.T) (*httptest.Server, chan forwardedRequest) {
+	t.Helper()
+
+	received := make(chan forwardedRequest, 1)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookies := []string{}
+		for _, cookie := range r.Cookies() {
+			cookies = append(cookies, cookie.Name+"="+cookie.Value)
+		}
+		received <- forwardedRequest{Path: r.URL.Path, Host: r.Host, Header: r.Header.Clone(), Cookies: cookies}
+
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	t.Cleanup(upstream.Close)
+
+	return upstream, received
+}
+
+// Authenticates every request as the same admin.
+func fakeRequireToken(allowedRoles []string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := auth.WithPrincipal(r.Context(), auth.Principal{UserID: "42", RoleSlug: "admin"})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func newProxyMux(t *testing.T, routes ...Route) *chi.Mux {
+	t.Helper()
+
+	for i := range routes {
+		if err := routes[i].prepare(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mux := chi.NewMux()
+	New(routes, fakeRequireToken, slog.New(slog.NewTextHandler(io.Discard, nil))).GenerateRoutes(mux)
+	return mux
+}
+
+func TestRouterForwards(t *testing.T) {
+	upstream, received := newRecordingUpstream(t)
+
+	tests := []struct {
+		name         string
+		route        Route
+		path         string
+		wantPath     string
+		wantUserId   string
+		wantUserRole string
+	}{
+		{
+			name:     "public route",
+			route:    Route{Prefix: "/v1/notes", Upstream: upstream.URL + "/api", StripPrefix: true},
+			path:     "/v1/notes/1",
+			wantPath: "/api/1",
+		},
+		{
+			name:         "authenticated route",
+			route:        Route{Prefix: "/v1/notes", Upstream: upstream.URL, RewritePrefix: "/notes", RequireAuth: true, AllowedRoles: []string{"admin"}},
+			path:         "/v1/notes/1",
+			wantPath:     "/notes/1",
+			wantUserId:   "42",
+			wantUserRole: "admin",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.route.Headers = map[string]string{"X-Forwarded-By": "api-gateway"}
+			mux := newProxyMux(t, tt.route)
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req = req.WithContext(context.WithValue(req.Context(), middleware.RequestIDKey, "request-1"))
+			// Spoofed identity and the caller's credentials to the gateway.
+			req.Header.Set("X-User-Id", "1")
+			req.Header.Set("X-User-Role", "admin")
+			req.Header.Set("Authorization", "Bearer gateway-token")
+			req.Header.Set(domain.CSRFTokenHeader, "csrf")
+			req.Header.Set("Accept", "application/json")
+			for _, cookie := range []string{"access-token", "refresh-token", "csrf-token", "theme"} {
+				req.AddCookie(&http.Cookie{Name: cookie, Value: "value"})
+			}
+
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusTeapot {
+				t.Fatalf("status = %d, want the upstream's %d", rec.Code, http.StatusTeapot)
+			}
+			if contentType := rec.Header().Get("Content-Type"); contentType != "text/plain" {
+				t.Errorf("Content-Type = %q, want the upstream's", contentType)
+			}
+
+			got := <-received
+			if got.Path != tt.wantPath {
+				t.Errorf("upstream path = %q, want %q", got.Path, tt.wantPath)
+			}
+			if got.Host != strings.TrimPrefix(upstream.URL, "http://") {
+				t.Errorf("upstream host = %q, want the upstream's", got.Host)
+			}
+
+			wantHeaders := map[string]string{
+				"X-User-Id":                tt.wantUserId,
+				"X-User-Role":              tt.wantUserRole,
+				"Authorization":            "",
+				domain.CSRFTokenHeader:     "",
+				middleware.RequestIDHeader: "request-1",
+				"X-Forwarded-By":           "api-gateway",
+				"Accept":                   "application/json",
+			}
+			for name, want := range wantHeaders {
+				if value := got.Header.Get(name); value != want {
+					t.Errorf("upstream header %s = %q, want %q", name, value, want)
+				}
+			}
+
+			if len(got.Cookies) != 1 || got.Cookies[0] != "theme=value" {
+				t.Errorf("upstream cookies = %v, want only theme=value", got.Cookies)
+			}
+		})
+	}
+}
+
+func TestRouterErrors(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer slow.Close()
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	tests := []struct {
+		name       string
+		route      Route
+		wantStatus int
+		wantCode   string
+	}{
+		{
+			name:       "unreachable upstream",
+			route:      Route{Prefix: "/v1/notes", Upstream: closed.URL},
+			wantStatus: http.StatusBadGateway,
+			wantCode:   domain.ErrCodeBadGateway,
+		},
+		{
+			name:       "slow upstream",
+			route:      Route{Prefix: "/v1/notes", Upstream: slow.URL, Timeout: Duration{50 * time.Millisecond}},
+			wantStatus: http.StatusGatewayTimeout,
+			wantCode:   domain.ErrCodeTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := newProxyMux(t, tt.route)
+
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/notes/1", nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if contentType := rec.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "application/json") {
+				t.Errorf("Content-Type = %q, want application/json", contentType)
+			}
+
+			var body domain.ErrorResponse
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Code != tt.wantCode {
+				t.Errorf("code = %q, want %q", body.Code, tt.wantCode)
+			}
+		})
+	}
+}
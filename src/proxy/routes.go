@@ -0,0 +1,124 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const defaultRouteTimeout = 30 * time.Second
+
+// A downstream HTTP service mounted on the gateway.
+type Route struct {
+	// Path prefix the service is mounted on, e.g. "/v1/notes".
+	Prefix string `json:"prefix"`
+	// Base URL of the downstream service.
+	Upstream string `json:"upstream"`
+	// Removes the prefix from the path before forwarding.
+	StripPrefix bool `json:"stripPrefix"`
+	// Replaces the prefix with this one before forwarding.
+	// Takes precedence over StripPrefix.
+	RewritePrefix string `json:"rewritePrefix"`
+	// Headers set on every forwarded request.
+	Headers map[string]string `json:"headers"`
+	// Timeout of the whole downstream call.
+	Timeout Duration `json:"timeout"`
+	// Puts the route behind the authorization middleware.
+	RequireAuth bool `json:"requireAuth"`
+	// Roles allowed on the route. Empty allows every authenticated user.
+	// Needs RequireAuth.
+	AllowedRoles []string `json:"allowedRoles"`
+
+	upstreamURL *url.URL
+}
+
+// time.Duration that is read from strings such as "5s" on JSON.
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var raw string
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+
+	parsed, err := time.ParseDuration(raw)
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+// Loads the route table from a JSON file.
+func LoadRoutes(path string) ([]Route, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading proxy routes: %w", err)
+	}
+
+	routes := []Route{}
+	if err := json.Unmarshal(content, &routes); err != nil {
+		return nil, fmt.Errorf("decoding proxy routes: %w", err)
+	}
+
+	for i := range routes {
+		if err := routes[i].prepare(); err != nil {
+			return nil, err
+		}
+	}
+	return routes, nil
+}
+
+// Validates the route and fills in the defaults.
+func (route *Route) prepare() error {
+	if !strings.HasPrefix(route.Prefix, "/") {
+		return fmt.Errorf("proxy route %q: prefix must start with /", route.Prefix)
+	}
+	route.Prefix = strings.TrimSuffix(route.Prefix, "/")
+
+	// Otherwise the route would be public, while meant to be restricted.
+	if len(route.AllowedRoles) > 0 && !route.RequireAuth {
+		return fmt.Errorf("proxy route %q: allowedRoles need requireAuth", route.Prefix)
+	}
+
+	upstreamURL, err := url.Parse(route.Upstream)
+	if err != nil || upstreamURL.Scheme == "" || upstreamURL.Host == "" {
+		return fmt.Errorf("proxy route %q: invalid upstream %q", route.Prefix, route.Upstream)
+	}
+	route.upstreamURL = upstreamURL
+
+	if route.Timeout.Duration <= 0 {
+		route.Timeout.Duration = defaultRouteTimeout
+	}
+	return nil
+}
+
+// Path to request on the upstream, for the path requested on the gateway.
+func (route Route) upstreamPath(requestPath string) string {
+	path := requestPath
+	switch {
+	case route.RewritePrefix != "":
+		path = route.RewritePrefix + strings.TrimPrefix(requestPath, route.Prefix)
+	case route.StripPrefix:
+		path = strings.TrimPrefix(requestPath, route.Prefix)
+	}
+
+	return singleJoiningSlash(route.upstreamURL.Path, path)
+}
+
+func singleJoiningSlash(a, b string) string {
+	aslash := strings.HasSuffix(a, "/")
+	bslash := strings.HasPrefix(b, "/")
+	switch {
+	case aslash && bslash:
+		return a + b[1:]
+	case !aslash && !bslash:
+		return a + "/" + b
+	}
+	return a + b
+}
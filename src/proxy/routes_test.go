@@ -0,0 +1,110 @@
+package proxy
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRoutePrepare(t *testing.T) {
+	tests := []struct {
+		name       string
+		route      Route
+		wantPrefix string
+		wantErr    bool
+	}{
+		{
+			name:       "public route",
+			route:      Route{Prefix: "/v1/notes", Upstream: "http://notes-service:8080"},
+			wantPrefix: "/v1/notes",
+		},
+		{
+			name:       "trailing slash",
+			route:      Route{Prefix: "/v1/notes/", Upstream: "http://notes-service:8080"},
+			wantPrefix: "/v1/notes",
+		},
+		{
+			name:       "roles with auth",
+			route:      Route{Prefix: "/v1/notes", Upstream: "http://notes-service:8080", RequireAuth: true, AllowedRoles: []string{"admin"}},
+			wantPrefix: "/v1/notes",
+		},
+		{
+			name:    "roles without auth",
+			route:   Route{Prefix: "/v1/notes", Upstream: "http://notes-service:8080", AllowedRoles: []string{"admin"}},
+			wantErr: true,
+		},
+		{
+			name:    "relative prefix",
+			route:   Route{Prefix: "v1/notes", Upstream: "http://notes-service:8080"},
+			wantErr: true,
+		},
+		{
+			name:    "upstream without scheme",
+			route:   Route{Prefix: "/v1/notes", Upstream: "notes-service:8080"},
+			wantErr: true,
+		},
+		{
+			name:    "upstream without host",
+			route:   Route{Prefix: "/v1/notes", Upstream: "http://"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route := tt.route
+			err := route.prepare()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("prepare() error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if route.Prefix != tt.wantPrefix {
+				t.Errorf("prefix = %q, want %q", route.Prefix, tt.wantPrefix)
+			}
+			if route.Timeout.Duration != defaultRouteTimeout {
+				t.Errorf("timeout = %v, want the default %v", route.Timeout.Duration, defaultRouteTimeout)
+			}
+		})
+	}
+}
+
+func TestRouteUpstreamPath(t *testing.T) {
+	tests := []struct {
+		name          string
+		upstream      string
+		stripPrefix   bool
+		rewritePrefix string
+		requestPath   string
+		want          string
+	}{
+		{"kept prefix", "http://notes-service:8080", false, "", "/v1/notes/1", "/v1/notes/1"},
+		{"kept prefix under base path", "http://notes-service:8080/api", false, "", "/v1/notes/1", "/api/v1/notes/1"},
+		{"base path with trailing slash", "http://notes-service:8080/api/", false, "", "/v1/notes/1", "/api/v1/notes/1"},
+		{"stripped prefix", "http://notes-service:8080", true, "", "/v1/notes/1", "/1"},
+		{"stripped prefix under base path", "http://notes-service:8080/api", true, "", "/v1/notes/1", "/api/1"},
+		{"stripped prefix only", "http://notes-service:8080/api", true, "", "/v1/notes", "/api/"},
+		{"rewritten prefix", "http://notes-service:8080", false, "/notes", "/v1/notes/1", "/notes/1"},
+		{"rewrite over strip", "http://notes-service:8080/api", true, "/notes", "/v1/notes/1", "/api/notes/1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route := Route{
+				Prefix:        "/v1/notes",
+				Upstream:      tt.upstream,
+				StripPrefix:   tt.stripPrefix,
+				RewritePrefix: tt.rewritePrefix,
+				Timeout:       Duration{time.Second},
+			}
+			if err := route.prepare(); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := route.upstreamPath(tt.requestPath); got != tt.want {
+				t.Errorf("upstreamPath(%q) = %q, want %q", tt.requestPath, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package revocation
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/plagioriginal/api-gateway/domain"
+)
+
+// Revocation list kept in memory, local to this replica.
+// Entries are dropped once their token expires, the ones of tokens
+// without expiry are kept until the gateway restarts.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	revoked map[string]time.Time
+}
+
+// Instantiates a new MemoryStore, removing the expired entries every
+// cleanupInterval until ctx is done.
+func NewMemoryStore(ctx context.Context, cleanupInterval time.Duration) domain.RevocationStore {
+	store := &MemoryStore{
+		revoked: map[string]time.Time{},
+	}
+
+	go store.cleanupEvery(ctx, cleanupInterval)
+	return store
+}
+
+func (s *MemoryStore) Revoke(ctx context.Context, tokenId string, expiresAt time.Time) error {
+	if !expiresAt.IsZero() && !time.Now().Before(expiresAt) {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.revoked[tokenId] = expiresAt
+	return nil
+}
+
+func (s *MemoryStore) IsRevoked(ctx context.Context, tokenId string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	expiresAt, found := s.revoked[tokenId]
+	return found && (expiresAt.IsZero() || time.Now().Before(expiresAt)), nil
+}
+
+func (s *MemoryStore) cleanupEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.removeExpired(now)
+		}
+	}
+}
+
+func (s *MemoryStore) removeExpired(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for tokenId, expiresAt := range s.revoked {
+		if !expiresAt.IsZero() && !now.Before(expiresAt) {
+			delete(s.revoked, tokenId)
+		}
+	}
+}
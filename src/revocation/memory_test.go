@@ -0,0 +1,108 @@
+package revocation
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreIsRevoked(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		revoke    bool
+		expiresAt time.Time
+		want      bool
+	}{
+		{name: "not revoked", want: false},
+		{name: "revoked until expiry", revoke: true, expiresAt: now.Add(time.Hour), want: true},
+		{name: "revoked without expiry", revoke: true, want: true},
+		{name: "revoked after expiry", revoke: true, expiresAt: now.Add(-time.Second), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			store := NewMemoryStore(ctx, time.Hour)
+			if tt.revoke {
+				if err := store.Revoke(ctx, "jti", tt.expiresAt); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			revoked, err := store.IsRevoked(ctx, "jti")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if revoked != tt.want {
+				t.Errorf("IsRevoked = %v, want %v", revoked, tt.want)
+			}
+			if revoked, _ := store.IsRevoked(ctx, "other-jti"); revoked {
+				t.Error("other token is revoked")
+			}
+		})
+	}
+}
+
+func TestMemoryStoreExpiredEntry(t *testing.T) {
+	// Entries expiring after they were stored are no longer revoked,
+	// even before the cleanup removes them.
+	store := &MemoryStore{revoked: map[string]time.Time{
+		"jti": time.Now().Add(-time.Second),
+	}}
+
+	revoked, err := store.IsRevoked(context.Background(), "jti")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revoked {
+		t.Error("expired entry is still revoked")
+	}
+}
+
+func TestMemoryStoreRemoveExpired(t *testing.T) {
+	now := time.Now()
+	store := &MemoryStore{revoked: map[string]time.Time{
+		"expired":        now.Add(-time.Second),
+		"expiring now":   now,
+		"valid":          now.Add(time.Hour),
+		"without expiry": {},
+	}}
+
+	store.removeExpired(now)
+
+	want := []string{"valid", "without expiry"}
+	if len(store.revoked) != len(want) {
+		t.Errorf("kept %d entries, want %d", len(store.revoked), len(want))
+	}
+	for _, tokenId := range want {
+		if _, found := store.revoked[tokenId]; !found {
+			t.Errorf("entry %q was removed", tokenId)
+		}
+	}
+}
+
+func TestMemoryStoreCleanup(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	store := NewMemoryStore(ctx, time.Millisecond).(*MemoryStore)
+	if err := store.Revoke(ctx, "jti", time.Now().Add(5*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		store.mu.RLock()
+		_, found := store.revoked["jti"]
+		store.mu.RUnlock()
+		if !found {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Error("expired entry was not cleaned up")
+}
@@ -10,30 +10,49 @@ import (
 type Router struct {
 	prefix              string
 	usersHandler        domain.UsersHttpHandler
+	csrfHandler         domain.CsrfHttpHandler
 	adminAuthMiddleware func(next http.Handler) http.Handler
+	authMiddleware      func(next http.Handler) http.Handler
+	csrfMiddleware      func(next http.Handler) http.Handler
 }
 
 func New(
 	prefix string,
 	usersHandler domain.UsersHttpHandler,
+	csrfHandler domain.CsrfHttpHandler,
 	adminAuthMiddleware func(next http.Handler) http.Handler,
+	authMiddleware func(next http.Handler) http.Handler,
+	csrfMiddleware func(next http.Handler) http.Handler,
 ) Router {
 	return Router{
 		prefix:              prefix,
 		usersHandler:        usersHandler,
+		csrfHandler:         csrfHandler,
 		adminAuthMiddleware: adminAuthMiddleware,
+		authMiddleware:      authMiddleware,
+		csrfMiddleware:      csrfMiddleware,
 	}
 }
 
 func (router Router) GenerateRoutes(mux *chi.Mux) {
+	mux.Get(router.prefix+"/csrf", router.csrfHandler.IssueToken)
+
 	mux.Route(router.prefix+"/users", func(r chi.Router) {
-		r.Post("/login", router.usersHandler.Login)
-		r.Post("/refresh", router.usersHandler.RefreshJWT)
-		r.Post("/logout", router.usersHandler.Logout)
+		r.Group(func(r chi.Router) {
+			r.Use(router.csrfMiddleware)
+			r.Post("/login", router.usersHandler.Login)
+			r.Post("/refresh", router.usersHandler.RefreshJWT)
+			r.Post("/logout", router.usersHandler.Logout)
+		})
 
 		r.Group(func(r chi.Router) {
 			r.Use(router.adminAuthMiddleware)
-			r.Get("/", router.usersHandler.AddUser)
+			r.Post("/", router.usersHandler.AddUser)
 		})
 	})
+
+	mux.Route(router.prefix+"/me", func(r chi.Router) {
+		r.Use(router.authMiddleware)
+		r.Get("/", router.usersHandler.GetMe)
+	})
 }
@@ -0,0 +1,282 @@
+package tokens
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Minimum time between two refreshes triggered by unknown key ids.
+const minOnDemandInterval = 30 * time.Second
+
+var (
+	ErrUnknownKey     = errors.New("no verification key for the token kid")
+	ErrKeyAlgMismatch = errors.New("verification key not meant for the token algorithm")
+	errUnsupportedKey = errors.New("unsupported json web key")
+)
+
+// Source of the public keys tokens are verified with.
+type KeySet interface {
+	// Returns the key with the given id, for a token signed with alg.
+	Key(kid string, alg string) (interface{}, error)
+}
+
+// A JSON Web Key (RFC 7517), with the fields needed for verification.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type verificationKey struct {
+	alg string
+	key interface{}
+}
+
+// Keys read from a JWKS document, either a local file or a URL.
+// Every key of the document is active, so tokens signed with the previous
+// key keep working while a new one is rolled out.
+type JWKSKeySet struct {
+	mu          sync.RWMutex
+	keys        map[string]verificationKey
+	lastAttempt time.Time
+	fetch       func(ctx context.Context) ([]byte, error)
+	refreshable bool
+	l           *slog.Logger
+}
+
+// Loads the keys from a local JWKS file.
+func NewJWKSFromFile(path string, l *slog.Logger) (*JWKSKeySet, error) {
+	ks := &JWKSKeySet{
+		fetch: func(ctx context.Context) ([]byte, error) {
+			return os.ReadFile(path)
+		},
+		l: l,
+	}
+
+	if err := ks.refresh(context.Background()); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// Loads the keys from a JWKS URL, and keeps refreshing them every
+// refreshInterval until ctx is done. When a refresh fails, the keys
+// already loaded are kept.
+func NewJWKSFromURL(ctx context.Context, url string, refreshInterval time.Duration, l *slog.Logger) (*JWKSKeySet, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	ks := &JWKSKeySet{
+		fetch: func(ctx context.Context) ([]byte, error) {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+			if err != nil {
+				return nil, err
+			}
+
+			res, err := client.Do(req)
+			if err != nil {
+				return nil, err
+			}
+			defer res.Body.Close()
+
+			if res.StatusCode != http.StatusOK {
+				return nil, fmt.Errorf("jwks endpoint answered %s", res.Status)
+			}
+			return io.ReadAll(io.LimitReader(res.Body, 1<<20))
+		},
+		refreshable: true,
+		l:           l,
+	}
+
+	if err := ks.refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	go ks.refreshEvery(ctx, refreshInterval)
+	return ks, nil
+}
+
+// Returns the key with the given id, for a token signed with alg.
+// Unknown ids on URL sets trigger an early refresh, since they usually
+// mean the keys were rotated.
+func (ks *JWKSKeySet) Key(kid string, alg string) (interface{}, error) {
+	key, found, stale := ks.lookup(kid)
+
+	if !found && stale {
+		if err := ks.refresh(context.Background()); err != nil {
+			ks.l.Warn("error refreshing jwks for unknown kid", "kid", kid, "error", err)
+		}
+		key, found, _ = ks.lookup(kid)
+	}
+
+	if !found {
+		return nil, ErrUnknownKey
+	}
+	if key.alg != "" && key.alg != alg {
+		return nil, ErrKeyAlgMismatch
+	}
+	return key.key, nil
+}
+
+func (ks *JWKSKeySet) lookup(kid string) (verificationKey, bool, bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	key, found := ks.keys[kid]
+	stale := ks.refreshable && time.Since(ks.lastAttempt) > minOnDemandInterval
+	return key, found, stale
+}
+
+func (ks *JWKSKeySet) refreshEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := ks.refresh(ctx); err != nil {
+				ks.l.Warn("error refreshing jwks, keeping the previous keys", "error", err)
+			}
+		}
+	}
+}
+
+// Fetches the document and replaces the keys.
+func (ks *JWKSKeySet) refresh(ctx context.Context) error {
+	ks.mu.Lock()
+	ks.lastAttempt = time.Now()
+	ks.mu.Unlock()
+
+	content, err := ks.fetch(ctx)
+	if err != nil {
+		return fmt.Errorf("fetching jwks: %w", err)
+	}
+
+	keys, err := parseJWKS(content, ks.l)
+	if err != nil {
+		return err
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	ks.keys = keys
+	return nil
+}
+
+// Parses the signature keys of a JWKS document, indexed by kid.
+// Keys that can't be used are skipped.
+func parseJWKS(content []byte, l *slog.Logger) (map[string]verificationKey, error) {
+	document := struct {
+		Keys []jsonWebKey `json:"keys"`
+	}{}
+	if err := json.Unmarshal(content, &document); err != nil {
+		return nil, fmt.Errorf("decoding jwks: %w", err)
+	}
+
+	keys := map[string]verificationKey{}
+	for _, jwk := range document.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		key, err := jwk.publicKey()
+		if err != nil {
+			l.Warn("skipping jwk", "kid", jwk.Kid, "error", err)
+			continue
+		}
+		keys[jwk.Kid] = verificationKey{alg: jwk.Alg, key: key}
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("jwks has no usable signature keys")
+	}
+	return keys, nil
+}
+
+// Builds the public key described by the JWK.
+func (jwk jsonWebKey) publicKey() (interface{}, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeBigInt(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errUnsupportedKey
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, errUnsupportedKey
+		}
+
+		x, err := decodeBigInt(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("ec point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	case "OKP":
+		if jwk.Crv != "Ed25519" {
+			return nil, errUnsupportedKey
+		}
+
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid ed25519 public key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+
+	return nil, errUnsupportedKey
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(raw) == 0 {
+		return nil, errors.New("invalid key parameter")
+	}
+	return new(big.Int).SetBytes(raw), nil
+}
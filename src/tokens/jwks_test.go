@@ -0,0 +1,247 @@
+package tokens
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func encodeBigInt(value *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(value.Bytes())
+}
+
+func rsaJWK(t *testing.T, kid string) (*rsa.PrivateKey, jsonWebKey) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, jsonWebKey{
+		Kty: "RSA",
+		Kid: kid,
+		Use: "sig",
+		Alg: "RS256",
+		N:   encodeBigInt(key.N),
+		E:   encodeBigInt(big.NewInt(int64(key.E))),
+	}
+}
+
+func ecJWK(t *testing.T, kid string) jsonWebKey {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return jsonWebKey{
+		Kty: "EC",
+		Kid: kid,
+		Crv: "P-256",
+		X:   encodeBigInt(key.X),
+		Y:   encodeBigInt(key.Y),
+	}
+}
+
+func ed25519JWK(t *testing.T, kid string) jsonWebKey {
+	t.Helper()
+
+	public, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return jsonWebKey{
+		Kty: "OKP",
+		Kid: kid,
+		Crv: "Ed25519",
+		X:   base64.RawURLEncoding.EncodeToString(public),
+	}
+}
+
+func jwksDocument(t *testing.T, keys ...jsonWebKey) []byte {
+	t.Helper()
+
+	content, err := json.Marshal(map[string][]jsonWebKey{"keys": keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
+
+func TestParseJWKS(t *testing.T) {
+	_, rsaKey := rsaJWK(t, "rsa")
+	ecKey := ecJWK(t, "ec")
+	edKey := ed25519JWK(t, "ed")
+
+	encryptionKey := ecJWK(t, "enc")
+	encryptionKey.Use = "enc"
+
+	offCurveKey := ecJWK(t, "off-curve")
+	offCurveKey.Y = encodeBigInt(big.NewInt(1))
+
+	unknownCurveKey := ecJWK(t, "unknown-curve")
+	unknownCurveKey.Crv = "P-192"
+
+	shortEdKey := ed25519JWK(t, "short-ed")
+	shortEdKey.X = base64.RawURLEncoding.EncodeToString([]byte("short"))
+
+	tests := []struct {
+		name     string
+		content  []byte
+		wantKids []string
+		wantErr  bool
+	}{
+		{
+			name:     "every key type",
+			content:  jwksDocument(t, rsaKey, ecKey, edKey),
+			wantKids: []string{"rsa", "ec", "ed"},
+		},
+		{
+			name:     "unusable keys are skipped",
+			content:  jwksDocument(t, rsaKey, encryptionKey, offCurveKey, unknownCurveKey, shortEdKey, jsonWebKey{Kty: "oct", Kid: "oct"}),
+			wantKids: []string{"rsa"},
+		},
+		{
+			name:    "no usable key",
+			content: jwksDocument(t, encryptionKey, offCurveKey),
+			wantErr: true,
+		},
+		{
+			name:    "empty document",
+			content: []byte(`{"keys": []}`),
+			wantErr: true,
+		},
+		{
+			name:    "malformed document",
+			content: []byte(`{"keys": `),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := parseJWKS(tt.content, discardLogger)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseJWKS() error = %v, want error %v", err, tt.wantErr)
+			}
+
+			if len(keys) != len(tt.wantKids) {
+				t.Errorf("parseJWKS() returned %d keys, want %d", len(keys), len(tt.wantKids))
+			}
+			for _, kid := range tt.wantKids {
+				if _, found := keys[kid]; !found {
+					t.Errorf("parseJWKS() is missing key %q", kid)
+				}
+			}
+		})
+	}
+}
+
+func TestJWKSKeySetKey(t *testing.T) {
+	_, rsaKey := rsaJWK(t, "rsa")
+	edKey := ed25519JWK(t, "ed")
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, jwksDocument(t, rsaKey, edKey), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	ks, err := NewJWKSFromFile(path, discardLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		kid     string
+		alg     string
+		wantErr error
+	}{
+		{"matching alg", "rsa", "RS256", nil},
+		{"other alg than the key's", "rsa", "RS512", ErrKeyAlgMismatch},
+		{"key without alg", "ed", "EdDSA", nil},
+		{"unknown kid", "missing", "RS256", ErrUnknownKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ks.Key(tt.kid, tt.alg)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Key(%q, %q) error = %v, want %v", tt.kid, tt.alg, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTokenManagerVerifiesWithJWKS(t *testing.T) {
+	privateKey, rsaKey := rsaJWK(t, "current")
+	otherKey, _ := rsaJWK(t, "other")
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, jwksDocument(t, rsaKey), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	ks, err := NewJWKSFromFile(path, discardLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tm, err := NewTokenManager("", ks, []string{"RS256"}, ValidationPolicy{RequiredClaims: []string{"exp", "sub"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func(key *rsa.PrivateKey, kid string) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, ClaimsWithRole{
+			StandardClaims: jwt.StandardClaims{Subject: "42", ExpiresAt: time.Now().Add(time.Minute).Unix()},
+		})
+		token.Header["kid"] = kid
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{"signed with the set's key", sign(privateKey, "current"), false},
+		{"signed with another key under the same kid", sign(otherKey, "current"), true},
+		{"unknown kid", sign(privateKey, "missing"), true},
+		{"hmac token", func() string {
+			signed, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, ClaimsWithRole{}).SignedString([]byte("secret"))
+			return signed
+		}(), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := tm.ParseToken(tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseToken() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !tm.IsTokenValid(token) {
+				t.Errorf("ParseToken() returned an invalid token")
+			}
+		})
+	}
+}
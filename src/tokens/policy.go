@@ -0,0 +1,116 @@
+package tokens
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/plagioriginal/api-gateway/helpers"
+)
+
+// Rules the claims of a token must follow, on top of a valid signature.
+type ValidationPolicy struct {
+	// Accepted "iss" values. Empty accepts any issuer.
+	Issuers []string
+	// Audience that must be listed on "aud". Empty skips the check.
+	Audience string
+	// Claims that must be present, e.g. "exp", "sub", "jti".
+	RequiredClaims []string
+	// Maximum time since "iat". Zero disables the check.
+	MaxAge time.Duration
+	// Clock skew tolerated on every time based claim.
+	Leeway time.Duration
+	// Reads the user ID from "iss" when the token has no "sub", as issued
+	// by older versions of the users service. Such tokens have no issuer
+	// to check, so they are rejected once Issuers is set.
+	LegacyIssuerAsSubject bool
+}
+
+// "aud" may be a single string or a list of them.
+type Audience []string
+
+func (a *Audience) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(b, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+// Checks the claims against the policy, at the given time.
+// Failures are reported as *jwt.ValidationError, like the ones
+// raised by the jwt package itself.
+func (p ValidationPolicy) Validate(claims *ClaimsWithRole, now time.Time) error {
+	for _, claim := range p.RequiredClaims {
+		if !claims.has(claim) {
+			return jwt.NewValidationError(fmt.Sprintf("token is missing the %q claim", claim), jwt.ValidationErrorClaimsInvalid)
+		}
+	}
+
+	if claims.ExpiresAt != 0 && now.After(time.Unix(claims.ExpiresAt, 0).Add(p.Leeway)) {
+		return jwt.NewValidationError("token is expired", jwt.ValidationErrorExpired)
+	}
+
+	if claims.NotBefore != 0 && now.Add(p.Leeway).Before(time.Unix(claims.NotBefore, 0)) {
+		return jwt.NewValidationError("token is not valid yet", jwt.ValidationErrorNotValidYet)
+	}
+
+	if claims.IssuedAt != 0 && now.Add(p.Leeway).Before(time.Unix(claims.IssuedAt, 0)) {
+		return jwt.NewValidationError("token used before issued", jwt.ValidationErrorIssuedAt)
+	}
+
+	if p.MaxAge > 0 {
+		if claims.IssuedAt == 0 {
+			return jwt.NewValidationError("token has no \"iat\" to check its age", jwt.ValidationErrorIssuedAt)
+		}
+		if now.After(time.Unix(claims.IssuedAt, 0).Add(p.MaxAge + p.Leeway)) {
+			return jwt.NewValidationError("token is too old", jwt.ValidationErrorExpired)
+		}
+	}
+
+	if len(p.Issuers) > 0 && !helpers.InArray(claims.Issuer, p.Issuers) {
+		return jwt.NewValidationError("token issuer is not accepted", jwt.ValidationErrorIssuer)
+	}
+
+	if p.Audience != "" && !helpers.InArray(p.Audience, claims.Audience) {
+		return jwt.NewValidationError("token is not meant for this audience", jwt.ValidationErrorAudience)
+	}
+
+	return nil
+}
+
+// Last moment the token passes the time based checks, which is later
+// than "exp" by the leeway. Zero when the token never expires.
+func (p ValidationPolicy) acceptedUntil(claims *ClaimsWithRole) time.Time {
+	until := time.Time{}
+	if claims.ExpiresAt != 0 {
+		until = time.Unix(claims.ExpiresAt, 0)
+	}
+	if p.MaxAge > 0 && claims.IssuedAt != 0 {
+		tooOld := time.Unix(claims.IssuedAt, 0).Add(p.MaxAge)
+		if until.IsZero() || tooOld.Before(until) {
+			until = tooOld
+		}
+	}
+
+	if until.IsZero() {
+		return until
+	}
+	return until.Add(p.Leeway)
+}
+
+// The user the token belongs to.
+func (p ValidationPolicy) userID(claims *ClaimsWithRole) string {
+	if claims.Subject == "" && p.LegacyIssuerAsSubject {
+		return claims.Issuer
+	}
+	return claims.Subject
+}
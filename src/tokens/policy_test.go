@@ -0,0 +1,187 @@
+package tokens
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+func TestValidationPolicyValidate(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	at := func(offset time.Duration) int64 {
+		return now.Add(offset).Unix()
+	}
+
+	tests := []struct {
+		name    string
+		policy  ValidationPolicy
+		claims  ClaimsWithRole
+		wantErr uint32
+	}{
+		{
+			name:   "valid token",
+			policy: ValidationPolicy{RequiredClaims: []string{"exp"}},
+			claims: ClaimsWithRole{StandardClaims: jwt.StandardClaims{ExpiresAt: at(time.Minute)}},
+		},
+		{
+			name:    "expired",
+			policy:  ValidationPolicy{},
+			claims:  ClaimsWithRole{StandardClaims: jwt.StandardClaims{ExpiresAt: at(-time.Second)}},
+			wantErr: jwt.ValidationErrorExpired,
+		},
+		{
+			name:   "expired within the leeway",
+			policy: ValidationPolicy{Leeway: 30 * time.Second},
+			claims: ClaimsWithRole{StandardClaims: jwt.StandardClaims{ExpiresAt: at(-29 * time.Second)}},
+		},
+		{
+			name:    "expired past the leeway",
+			policy:  ValidationPolicy{Leeway: 30 * time.Second},
+			claims:  ClaimsWithRole{StandardClaims: jwt.StandardClaims{ExpiresAt: at(-31 * time.Second)}},
+			wantErr: jwt.ValidationErrorExpired,
+		},
+		{
+			name:    "not valid yet",
+			policy:  ValidationPolicy{},
+			claims:  ClaimsWithRole{StandardClaims: jwt.StandardClaims{NotBefore: at(time.Second)}},
+			wantErr: jwt.ValidationErrorNotValidYet,
+		},
+		{
+			name:   "not valid yet within the leeway",
+			policy: ValidationPolicy{Leeway: 30 * time.Second},
+			claims: ClaimsWithRole{StandardClaims: jwt.StandardClaims{NotBefore: at(29 * time.Second)}},
+		},
+		{
+			name:    "issued in the future past the leeway",
+			policy:  ValidationPolicy{Leeway: 30 * time.Second},
+			claims:  ClaimsWithRole{StandardClaims: jwt.StandardClaims{IssuedAt: at(31 * time.Second)}},
+			wantErr: jwt.ValidationErrorIssuedAt,
+		},
+		{
+			name:    "missing required exp",
+			policy:  ValidationPolicy{RequiredClaims: []string{"exp"}},
+			claims:  ClaimsWithRole{},
+			wantErr: jwt.ValidationErrorClaimsInvalid,
+		},
+		{
+			name:    "missing required jti",
+			policy:  ValidationPolicy{RequiredClaims: []string{"exp", "jti"}},
+			claims:  ClaimsWithRole{StandardClaims: jwt.StandardClaims{ExpiresAt: at(time.Minute)}},
+			wantErr: jwt.ValidationErrorClaimsInvalid,
+		},
+		{
+			name:   "every required claim present",
+			policy: ValidationPolicy{RequiredClaims: []string{"exp", "iat", "sub", "jti", "aud"}},
+			claims: ClaimsWithRole{
+				Audience:       Audience{"gateway"},
+				StandardClaims: jwt.StandardClaims{ExpiresAt: at(time.Minute), IssuedAt: at(0), Subject: "42", Id: "abc"},
+			},
+		},
+		{
+			name:   "max age within the leeway",
+			policy: ValidationPolicy{MaxAge: time.Hour, Leeway: 30 * time.Second},
+			claims: ClaimsWithRole{StandardClaims: jwt.StandardClaims{IssuedAt: at(-time.Hour - 29*time.Second)}},
+		},
+		{
+			name:    "max age past the leeway",
+			policy:  ValidationPolicy{MaxAge: time.Hour, Leeway: 30 * time.Second},
+			claims:  ClaimsWithRole{StandardClaims: jwt.StandardClaims{IssuedAt: at(-time.Hour - 31*time.Second)}},
+			wantErr: jwt.ValidationErrorExpired,
+		},
+		{
+			name:    "max age without iat",
+			policy:  ValidationPolicy{MaxAge: time.Hour},
+			claims:  ClaimsWithRole{},
+			wantErr: jwt.ValidationErrorIssuedAt,
+		},
+		{
+			name:   "accepted issuer",
+			policy: ValidationPolicy{Issuers: []string{"users-service"}},
+			claims: ClaimsWithRole{StandardClaims: jwt.StandardClaims{Issuer: "users-service", Subject: "42"}},
+		},
+		{
+			name:    "other issuer",
+			policy:  ValidationPolicy{Issuers: []string{"users-service"}},
+			claims:  ClaimsWithRole{StandardClaims: jwt.StandardClaims{Issuer: "evil", Subject: "42"}},
+			wantErr: jwt.ValidationErrorIssuer,
+		},
+		{
+			name:   "legacy token without issuers",
+			policy: ValidationPolicy{LegacyIssuerAsSubject: true},
+			claims: ClaimsWithRole{StandardClaims: jwt.StandardClaims{Issuer: "42"}},
+		},
+		{
+			name:    "legacy token with issuers",
+			policy:  ValidationPolicy{Issuers: []string{"users-service"}, LegacyIssuerAsSubject: true},
+			claims:  ClaimsWithRole{StandardClaims: jwt.StandardClaims{Issuer: "42"}},
+			wantErr: jwt.ValidationErrorIssuer,
+		},
+		{
+			name:    "other audience",
+			policy:  ValidationPolicy{Audience: "gateway"},
+			claims:  ClaimsWithRole{Audience: Audience{"other"}},
+			wantErr: jwt.ValidationErrorAudience,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Validate(&tt.claims, now)
+
+			if tt.wantErr == 0 {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want no error", err)
+				}
+				return
+			}
+
+			var validationErr *jwt.ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("Validate() = %v, want a *jwt.ValidationError", err)
+			}
+			if validationErr.Errors&tt.wantErr == 0 {
+				t.Errorf("Validate() errors = %b, want %b", validationErr.Errors, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidationPolicyAcceptedUntil(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+
+	tests := []struct {
+		name   string
+		policy ValidationPolicy
+		claims ClaimsWithRole
+		want   time.Time
+	}{
+		{
+			name:   "exp plus the leeway",
+			policy: ValidationPolicy{Leeway: 30 * time.Second},
+			claims: ClaimsWithRole{StandardClaims: jwt.StandardClaims{ExpiresAt: now.Unix()}},
+			want:   now.Add(30 * time.Second),
+		},
+		{
+			name:   "max age before exp",
+			policy: ValidationPolicy{MaxAge: time.Minute, Leeway: time.Second},
+			claims: ClaimsWithRole{StandardClaims: jwt.StandardClaims{IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Hour).Unix()}},
+			want:   now.Add(time.Minute + time.Second),
+		},
+		{
+			name:   "never expires",
+			policy: ValidationPolicy{Leeway: 30 * time.Second},
+			claims: ClaimsWithRole{},
+			want:   time.Time{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.acceptedUntil(&tt.claims); !got.Equal(tt.want) {
+				t.Errorf("acceptedUntil() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,8 +1,14 @@
 package tokens
 
 import (
+	"errors"
+	"fmt"
+	"time"
+
 	"github.com/golang-jwt/jwt"
+	"github.com/plagioriginal/api-gateway/auth"
 	"github.com/plagioriginal/api-gateway/domain"
+	"github.com/plagioriginal/api-gateway/helpers"
 )
 
 // Our custom claimes for the JWT Token
@@ -10,37 +16,98 @@ type ClaimsWithRole struct {
 	UserRoleSlug  string `json:"roleSlug"`
 	UserRoleLabel string `json:"roleLabel"`
 	Username      string `json:"username"`
+	// Replaces the one of StandardClaims, which can't read lists.
+	Audience Audience `json:"aud,omitempty"`
 	jwt.StandardClaims
 }
 
+// Checks if a registered claim is present on the token.
+func (c *ClaimsWithRole) has(claim string) bool {
+	switch claim {
+	case "exp":
+		return c.ExpiresAt != 0
+	case "iat":
+		return c.IssuedAt != 0
+	case "nbf":
+		return c.NotBefore != 0
+	case "iss":
+		return c.Issuer != ""
+	case "sub":
+		return c.Subject != ""
+	case "aud":
+		return len(c.Audience) > 0
+	case "jti":
+		return c.Id != ""
+	}
+	return false
+}
+
 // Object used to manage token/auth operations
 type DefaultTokenManager struct {
-	JWTSecret string
+	JWTSecret         string
+	keys              KeySet
+	allowedAlgorithms []string
+	policy            ValidationPolicy
 }
 
 // Instantiates a new Token Manager
-func NewTokenManager(jwtSecret string) domain.TokenManager {
-	return DefaultTokenManager{
-		JWTSecret: jwtSecret,
+// Only tokens signed with one of the allowed algorithms are accepted.
+// HMAC algorithms are verified with the secret, and the asymmetric ones
+// (RS*, ES*, EdDSA) with the key of the set matching the token "kid".
+// The claims are then checked against the validation policy.
+func NewTokenManager(
+	jwtSecret string,
+	keys KeySet,
+	allowedAlgorithms []string,
+	policy ValidationPolicy,
+) (domain.TokenManager, error) {
+	if len(allowedAlgorithms) == 0 {
+		return nil, errors.New("no jwt algorithms allowed")
 	}
-}
 
-// Gets the user ID from token
-func (t DefaultTokenManager) GetTokenIssuer(token *jwt.Token) (string, error) {
-	if claims, ok := token.Claims.(*ClaimsWithRole); ok && t.IsTokenValid(token) {
-		return claims.StandardClaims.Issuer, nil
+	for _, alg := range allowedAlgorithms {
+		switch jwt.GetSigningMethod(alg).(type) {
+		case *jwt.SigningMethodHMAC:
+			if jwtSecret == "" {
+				return nil, fmt.Errorf("jwt algorithm %s needs a secret", alg)
+			}
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS, *jwt.SigningMethodECDSA, *jwt.SigningMethodEd25519:
+			if keys == nil {
+				return nil, fmt.Errorf("jwt algorithm %s needs a jwks", alg)
+			}
+		default:
+			return nil, fmt.Errorf("jwt algorithm %q is not supported", alg)
+		}
 	}
 
-	return "", domain.ErrInvalidToken
+	return DefaultTokenManager{
+		JWTSecret:         jwtSecret,
+		keys:              keys,
+		allowedAlgorithms: allowedAlgorithms,
+		policy:            policy,
+	}, nil
 }
 
-// Gets the user role from a jwt token
-func (t DefaultTokenManager) GetTokenRole(token *jwt.Token) (string, error) {
-	if claims, ok := token.Claims.(*ClaimsWithRole); ok && t.IsTokenValid(token) {
-		return claims.UserRoleSlug, nil
+// Gets the authenticated identity from a jwt token
+func (t DefaultTokenManager) GetPrincipal(token *jwt.Token) (auth.Principal, error) {
+	claims, ok := token.Claims.(*ClaimsWithRole)
+	if !ok || !t.IsTokenValid(token) {
+		return auth.Principal{}, domain.ErrInvalidToken
 	}
 
-	return "", domain.ErrInvalidToken
+	userID := t.policy.userID(claims)
+	if userID == "" {
+		return auth.Principal{}, domain.ErrInvalidToken
+	}
+
+	return auth.Principal{
+		UserID:    userID,
+		Username:  claims.Username,
+		RoleSlug:  claims.UserRoleSlug,
+		RoleLabel: claims.UserRoleLabel,
+		TokenID:   claims.StandardClaims.Id,
+		ExpiresAt: t.policy.acceptedUntil(claims),
+	}, nil
 }
 
 // Checks if a token is valid
@@ -50,9 +117,37 @@ func (t DefaultTokenManager) IsTokenValid(token *jwt.Token) bool {
 
 // Parses a JWT Token string to an object.
 func (t DefaultTokenManager) ParseToken(tokenString string) (*jwt.Token, error) {
-	key := []byte(t.JWTSecret)
+	// The claims are checked by the policy instead, which accounts for
+	// clock skew.
+	parser := &jwt.Parser{
+		ValidMethods:         t.allowedAlgorithms,
+		SkipClaimsValidation: true,
+	}
+
+	token, err := parser.ParseWithClaims(tokenString, &ClaimsWithRole{}, t.verificationKey)
+	if err != nil {
+		return token, err
+	}
+
+	if err := t.policy.Validate(token.Claims.(*ClaimsWithRole), time.Now()); err != nil {
+		token.Valid = false
+		return token, err
+	}
+	return token, nil
+}
+
+// Picks the key to verify a token with, never trusting the token
+// to choose the kind of verification.
+func (t DefaultTokenManager) verificationKey(token *jwt.Token) (interface{}, error) {
+	alg := token.Method.Alg()
+	if !helpers.InArray(alg, t.allowedAlgorithms) {
+		return nil, fmt.Errorf("jwt algorithm %q is not allowed", alg)
+	}
+
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		return []byte(t.JWTSecret), nil
+	}
 
-	return jwt.ParseWithClaims(tokenString, &ClaimsWithRole{}, func(t *jwt.Token) (interface{}, error) {
-		return key, nil
-	})
+	kid, _ := token.Header["kid"].(string)
+	return t.keys.Key(kid, alg)
 }
@@ -0,0 +1,116 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+
+	"github.com/plagioriginal/api-gateway/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/plagioriginal/api-gateway"
+
+// The tracer of the gateway spans.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Sets up the global tracer provider and the W3C trace context
+// propagation. The returned function flushes the pending spans, and
+// must be called on shutdown.
+func Setup(ctx context.Context, cfg config.Tracing) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	if cfg.Exporter == "none" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, closeOutput, err := newExporter(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(newSampler(cfg)),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceNameKey.String(cfg.ServiceName),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closeErr := closeOutput(); err == nil {
+			err = closeErr
+		}
+		return err
+	}, nil
+}
+
+func newExporter(ctx context.Context, cfg config.Tracing) (sdktrace.SpanExporter, func() error, error) {
+	noClose := func() error { return nil }
+
+	switch cfg.Exporter {
+	case "otlp":
+		// Without an endpoint, the exporter reads the standard OTEL_EXPORTER_OTLP_* variables.
+		options := []otlptracehttp.Option{}
+		if cfg.OTLPEndpoint != "" {
+			endpoint, err := url.Parse(cfg.OTLPEndpoint)
+			if err != nil || endpoint.Host == "" {
+				return nil, nil, fmt.Errorf("invalid otlp endpoint %q", cfg.OTLPEndpoint)
+			}
+			options = append(options, otlptracehttp.WithEndpoint(endpoint.Host))
+			if endpoint.Scheme == "http" {
+				options = append(options, otlptracehttp.WithInsecure())
+			}
+		}
+
+		exporter, err := otlptracehttp.New(ctx, options...)
+		return exporter, noClose, err
+
+	case "stdout":
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		return exporter, noClose, err
+
+	case "file":
+		file, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("opening the traces file: %w", err)
+		}
+
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(io.Writer(file)))
+		return exporter, file.Close, err
+	}
+
+	return nil, nil, fmt.Errorf("unknown tracing exporter %q", cfg.Exporter)
+}
+
+// Follows the decision of the caller when the request carries a
+// traceparent, so a trace isn't cut at the gateway.
+func newSampler(cfg config.Tracing) sdktrace.Sampler {
+	var root sdktrace.Sampler
+	switch cfg.Sampler {
+	case "always_off":
+		root = sdktrace.NeverSample()
+	case "traceidratio":
+		root = sdktrace.TraceIDRatioBased(cfg.SampleRatio)
+	default:
+		root = sdktrace.AlwaysSample()
+	}
+	return sdktrace.ParentBased(root)
+}
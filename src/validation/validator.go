@@ -0,0 +1,84 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/locales/en"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	enTranslations "github.com/go-playground/validator/v10/translations/en"
+	"github.com/plagioriginal/api-gateway/domain"
+)
+
+// Validates requests, reporting the failures per field with
+// translated messages.
+type Validator struct {
+	validate   *validator.Validate
+	translator ut.Translator
+}
+
+// Instantiates a new Validator with english messages.
+func New() (*Validator, error) {
+	validate := validator.New()
+
+	// Report the fields with the names the clients know them by.
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
+	english := en.New()
+	translator, _ := ut.New(english, english).GetTranslator("en")
+	if err := enTranslations.RegisterDefaultTranslations(validate, translator); err != nil {
+		return nil, fmt.Errorf("registering validation translations: %w", err)
+	}
+
+	return &Validator{
+		validate:   validate,
+		translator: translator,
+	}, nil
+}
+
+// Decodes the JSON body of the request into 'dst' and validates it.
+// Fields of 'dst' that are not read from the body (json:"-") can be set
+// beforehand, and are validated as well.
+// Returns domain.ErrInvalidRequestBody or domain.ValidationErrors.
+func (v *Validator) DecodeAndValidate(r *http.Request, dst interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(dst); err != nil {
+		return fmt.Errorf("%w: %v", domain.ErrInvalidRequestBody, err)
+	}
+
+	return v.Validate(dst)
+}
+
+// Validates a struct, returning domain.ValidationErrors on failure.
+func (v *Validator) Validate(s interface{}) error {
+	err := v.validate.Struct(s)
+	if err == nil {
+		return nil
+	}
+
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return err
+	}
+
+	result := make(domain.ValidationErrors, len(validationErrors))
+	for i, fe := range validationErrors {
+		result[i] = domain.FieldError{
+			Field:   fe.Field(),
+			Rule:    fe.Tag(),
+			Param:   fe.Param(),
+			Message: fe.Translate(v.translator),
+		}
+	}
+	return result
+}